@@ -0,0 +1,103 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jeehoon/arktools/pkg/chatlog"
+)
+
+// chatCmd represents the chat command
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Chat archive of the ChatBot",
+}
+
+// chatSearchCmd represents the chat search command
+var chatSearchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Search the chat archive",
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(doChatSearch())
+	},
+}
+
+func init() {
+	cobra.OnInitialize(setDefaults)
+
+	rootCmd.AddCommand(chatCmd)
+	chatCmd.AddCommand(chatSearchCmd)
+
+	chatSearchCmd.Flags().String("player", "", "survivor name")
+	chatSearchCmd.Flags().String("since", "24h", "oldest message, a duration (24h) or a date (2006-01-02)")
+	chatSearchCmd.Flags().String("contains", "", "text of the message")
+	chatSearchCmd.Flags().Int("limit", 0, "print only the newest messages (0 prints all)")
+
+	cobra.CheckErr(viper.BindPFlags(chatSearchCmd.Flags()))
+}
+
+func doChatSearch() (err error) {
+	dir := viper.GetString("chat-archive")
+	if dir == "" {
+		return errors.Errorf("--chat-archive is not set")
+	}
+
+	since, err := parseSince(viper.GetString("since"))
+	if err != nil {
+		return errors.Wrap(err, "parseSince")
+	}
+
+	archive, err := chatlog.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "chatlog.Open(%v)", dir)
+	}
+
+	entries, err := archive.Search(&chatlog.Query{
+		Player:   viper.GetString("player"),
+		Since:    since,
+		Contains: viper.GetString("contains"),
+		Limit:    viper.GetInt("limit"),
+	})
+	if err != nil {
+		return errors.Wrap(err, "archive.Search")
+	}
+
+	if isJsonFormat() {
+		enc := json.NewEncoder(Output)
+		for _, entry := range entries {
+			if err := enc.Encode(entry); err != nil {
+				return errors.Wrap(err, "json.Encode")
+			}
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		fmt.Fprintln(Output, entry)
+	}
+	return nil
+}
+
+func parseSince(s string) (since time.Time, err error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	since, err = time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid since %q, neither a duration nor a date", s)
+	}
+	return since, nil
+}
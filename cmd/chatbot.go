@@ -5,12 +5,19 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/jeehoon/arktools/pkg/chatbot"
+	"github.com/jeehoon/arktools/pkg/chatlog"
+	"github.com/jeehoon/arktools/pkg/rcon"
 )
 
 // chatbotCmd represents the chatbot command
@@ -18,7 +25,8 @@ var chatbotCmd = &cobra.Command{
 	Use:   "chatbot",
 	Short: "ChatBot Agent",
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 		cobra.CheckErr(doChatBot(ctx, args))
 	},
 }
@@ -29,10 +37,43 @@ func init() {
 	rootCmd.AddCommand(chatbotCmd)
 
 	chatbotCmd.Flags().String("api-token", "", "discord api token")
-	chatbotCmd.Flags().String("webdis-addr", "http://127.0.0.1:7379", "webdus address")
+	chatbotCmd.Flags().String("webdis-addr", "http://127.0.0.1:7379", "webdis address")
+	chatbotCmd.Flags().String("redis-addr", "", "redis address (host:port), used instead of webdis when set")
+	chatbotCmd.Flags().String("redis-username", "", "redis ACL username")
+	chatbotCmd.Flags().String("redis-password", "", "redis password")
+	chatbotCmd.Flags().Int("redis-db", 0, "redis database number")
+	chatbotCmd.Flags().Bool("redis-tls", false, "connect to redis over TLS")
+	chatbotCmd.Flags().Bool("redis-tls-insecure", false, "skip redis TLS certificate verification")
 	chatbotCmd.Flags().String("chat-cluster", "MyCluster", "chat cluster id")
+	chatbotCmd.Flags().String("delivery", "poll", "in-game message delivery: poll (LRANGE every 500ms), subscribe (pub/sub channel) or queue (BRPOP of a list of the bot)")
+	chatbotCmd.Flags().String("chat-channel", "", "pub/sub channel of subscribe delivery without routes in the config (default is the chat cluster id)")
+	chatbotCmd.Flags().String("chat-queue", "", "list of queue delivery without routes in the config, the game servers must LPUSH every message on it too")
 	chatbotCmd.Flags().String("channel-id", "MyCluster", "discord channel id")
 	chatbotCmd.Flags().String("chat-fmt", "```md\n[%v][%v][%v]: %v\n```", "discord display format")
+	chatbotCmd.Flags().String("chat-style", "text", "discord display style: text (chat-fmt) or embed")
+	chatbotCmd.Flags().Bool("webhook", false, "send in-game messages through a channel webhook as \"[Tribe] Survivor (Map)\"")
+	chatbotCmd.Flags().String("avatar", "", "avatar url of the webhook messages")
+	chatbotCmd.Flags().Duration("batch-window", time.Second, "coalesce the in-game messages of a burst within this window into one discord message")
+	chatbotCmd.Flags().Bool("join-leave", false, "post player join and leave messages (needs servers in the config)")
+	chatbotCmd.Flags().String("player-count", "", "show the player count in the channel topic or the bot presence: topic, presence")
+	chatbotCmd.Flags().Bool("tribe-log", false, "post the tribe log of the servers")
+	chatbotCmd.Flags().Duration("events-interval", 30*time.Second, "how often the servers are polled for events")
+	chatbotCmd.Flags().String("state-file", "", "persist the forwarding cursor to this file")
+	chatbotCmd.Flags().Int("catchup-max", 10, "max messages sent while the bot was down to forward on restart")
+	chatbotCmd.Flags().String("guild-id", "", "discord guild id of the slash commands (global commands when empty)")
+	chatbotCmd.Flags().StringSlice("admin-roles", nil, "discord role ids allowed to run slash commands")
+	chatbotCmd.Flags().String("audit-log", "", "append slash command invocations to this file")
+	chatbotCmd.Flags().Duration("retention", 24*time.Hour, "delete discord messages older than this (0 disables)")
+	chatbotCmd.Flags().Duration("retention-interval", 3*time.Hour, "how often old discord messages are deleted")
+	chatbotCmd.Flags().Bool("retention-keep-pinned", false, "keep pinned messages")
+	chatbotCmd.Flags().Bool("retention-only-bot", false, "delete only the messages of the bot")
+	chatbotCmd.Flags().String("retention-archive", "", "append deleted messages to this file as json lines")
+	chatbotCmd.Flags().String("identity-file", "", "persist the survivors discord users link with /link to this file (enables /link)")
+	chatbotCmd.Flags().String("health-addr", "", "serve /healthz and /readyz on this address (e.g. :8080)")
+	chatbotCmd.Flags().String("chat-archive", "", "archive the relayed messages in this dir (enables /chatlog)")
+
+	// chat search reads the archive of the chatbot
+	chatSearchCmd.Flags().AddFlag(chatbotCmd.Flags().Lookup("chat-archive"))
 
 	cobra.CheckErr(viper.BindPFlags(chatbotCmd.Flags()))
 }
@@ -40,14 +81,156 @@ func init() {
 func doChatBot(ctx context.Context, args []string) (err error) {
 	apiToken := viper.GetString("api-token")
 	webdisAddr := viper.GetString("webdis-addr")
-	chatCluster := viper.GetString("chat-cluster")
-	channelId := viper.GetString("channel-id")
-	chatFmt := viper.GetString("chat-fmt")
 
-	cb := chatbot.New(apiToken, webdisAddr, chatCluster, channelId, chatFmt)
+	routes, err := chatRoutes()
+	if err != nil {
+		return errors.Wrap(err, "chatRoutes")
+	}
+
+	cb := chatbot.New(newTransport(webdisAddr), routes)
+	if apiToken != "" {
+		cb.AddBridge(chatbot.NewDiscord(apiToken))
+	}
+
+	// bridges:
+	//   - name: IRC
+	//     type: irc
+	//     addr: irc.libera.chat:6697
+	//     tls: true
+	var bridges []*chatbot.BridgeConfig
+	if err := viper.UnmarshalKey("bridges", &bridges); err != nil {
+		return errors.Wrap(err, "viper.UnmarshalKey(bridges)")
+	}
+	for _, config := range bridges {
+		bridge, err := chatbot.NewBridge(config)
+		if err != nil {
+			return errors.Wrap(err, "chatbot.NewBridge")
+		}
+		cb.AddBridge(bridge)
+	}
+	cb.SetState(viper.GetString("state-file"), viper.GetInt("catchup-max"))
+
+	// servers:
+	//   - name: TheIsland
+	//     rcon-addr: 127.0.0.1:32330
+	//     password: secret
+	var servers []*rcon.Server
+	if err := viper.UnmarshalKey("servers", &servers); err != nil {
+		return errors.Wrap(err, "viper.UnmarshalKey(servers)")
+	}
+	cb.SetCommands(viper.GetString("guild-id"), servers, viper.GetStringSlice("admin-roles"), viper.GetString("audit-log"))
+	cb.SetEventsInterval(viper.GetDuration("events-interval"))
+
+	// moderation:
+	//   rules:
+	//     - words: [badword]
+	//       action: replace
+	//   muted: ["123456789012345678", "Survivor Name"]
+	//   spam:
+	//     window: 1m
+	//     max-repeats: 3
+	//     max-messages: 10
+	if viper.IsSet("moderation") {
+		var moderation *chatbot.Moderation
+		if err := viper.UnmarshalKey("moderation", &moderation); err != nil {
+			return errors.Wrap(err, "viper.UnmarshalKey(moderation)")
+		}
+		if err := cb.SetModeration(moderation); err != nil {
+			return errors.Wrap(err, "cb.SetModeration")
+		}
+	}
+
+	if dir := viper.GetString("chat-archive"); dir != "" {
+		archive, err := chatlog.Open(dir)
+		if err != nil {
+			return errors.Wrapf(err, "chatlog.Open(%v)", dir)
+		}
+		cb.SetArchive(archive)
+	}
+
+	if path := viper.GetString("identity-file"); path != "" {
+		if err := cb.SetIdentities(path); err != nil {
+			return errors.Wrap(err, "cb.SetIdentities")
+		}
+	}
+
+	cb.SetHealthAddr(viper.GetString("health-addr"))
 
-	if err := cb.Serve(ctx); err != nil {
+	// SIGINT and SIGTERM cancel ctx, a clean shutdown
+	if err := cb.Serve(ctx); err != nil && !errors.Is(err, context.Canceled) {
 		return errors.Wrap(err, "Serve")
 	}
 	return nil
 }
+
+// chatRoutes reads the routing table from the config file. Without one, the
+// flags describe a single route.
+func chatRoutes() (routes []*chatbot.Route, err error) {
+	if err := viper.UnmarshalKey("routes", &routes); err != nil {
+		return nil, errors.Wrap(err, "viper.UnmarshalKey(routes)")
+	}
+
+	// the chat channel and queue flags are of this route only, routes of
+	// the config default to the channel of their own cluster
+	if len(routes) == 0 {
+		routes = append(routes, &chatbot.Route{
+			Cluster:     viper.GetString("chat-cluster"),
+			ChannelId:   viper.GetString("channel-id"),
+			Format:      viper.GetString("chat-fmt"),
+			Style:       viper.GetString("chat-style"),
+			Webhook:     viper.GetBool("webhook"),
+			Avatar:      viper.GetString("avatar"),
+			ChatChannel: viper.GetString("chat-channel"),
+			Queue:       viper.GetString("chat-queue"),
+		})
+	}
+
+	for _, route := range routes {
+		if route.Delivery == "" {
+			route.Delivery = viper.GetString("delivery")
+		}
+		if route.BatchWindow == 0 {
+			route.BatchWindow = viper.GetDuration("batch-window")
+		}
+		if route.Events == nil && (viper.GetBool("join-leave") || viper.GetString("player-count") != "" || viper.GetBool("tribe-log")) {
+			route.Events = &chatbot.Events{
+				JoinLeave:   viper.GetBool("join-leave"),
+				PlayerCount: viper.GetString("player-count"),
+				TribeLog:    viper.GetBool("tribe-log"),
+			}
+		}
+		if route.Retention == nil {
+			route.Retention = &chatbot.Retention{
+				Age:        viper.GetDuration("retention"),
+				Interval:   viper.GetDuration("retention-interval"),
+				KeepPinned: viper.GetBool("retention-keep-pinned"),
+				OnlyBot:    viper.GetBool("retention-only-bot"),
+				Archive:    viper.GetString("retention-archive"),
+			}
+		}
+	}
+
+	return routes, nil
+}
+
+func newTransport(webdisAddr string) chatbot.Transport {
+	redisAddr := viper.GetString("redis-addr")
+	if redisAddr == "" {
+		return chatbot.NewWebdis(webdisAddr)
+	}
+
+	var tlsConfig *tls.Config
+	if viper.GetBool("redis-tls") {
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: viper.GetBool("redis-tls-insecure"),
+		}
+	}
+
+	return chatbot.NewRedis(
+		redisAddr,
+		viper.GetString("redis-username"),
+		viper.GetString("redis-password"),
+		viper.GetInt("redis-db"),
+		tlsConfig,
+	)
+}
@@ -3,17 +3,27 @@ package cmd
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
+
+	"github.com/jeehoon/arktools/pkg/clustersav"
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/jeehoon/arktools/pkg/rcon"
 )
 
 // fcssCmd represents the fcss command
@@ -21,8 +31,87 @@ var fcssCmd = &cobra.Command{
 	Use:   "fcss",
 	Short: "FCSS ARK Server",
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
-		cobra.CheckErr(doFCSS(ctx, args))
+		cobra.CheckErr(doFCSS())
+	},
+}
+
+// fcssListCmd represents the fcss list command
+var fcssListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the players of the cluster",
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(doFCSSList())
+	},
+}
+
+// fcssShowCmd represents the fcss show command
+var fcssShowCmd = &cobra.Command{
+	Use:   "show <steamid>",
+	Short: "Show the uploaded survivor, items and creatures of a player",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(doFCSSShow(args[0]))
+	},
+}
+
+// fcssSnapshotCmd represents the fcss snapshot command
+var fcssSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Store a timestamped copy of the cluster data of every player",
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(doFCSSSnapshot())
+	},
+}
+
+// fcssDiffCmd represents the fcss diff command
+var fcssDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Report the items and creatures added and removed per player between two snapshots",
+	Long: `Report the items and creatures added and removed per player between two
+snapshots. A snapshot is a path, a file name in --snapshot-dir, "latest" for
+the newest snapshot or "current" for the cluster data as it is now.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(doFCSSDiff(args[0], args[1]))
+	},
+}
+
+// fcssWatchCmd represents the fcss watch command
+var fcssWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Stream the uploads and downloads of the cluster as json lines",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		cobra.CheckErr(doFCSSWatch(ctx))
+	},
+}
+
+// fcssRestoreCmd represents the fcss restore command
+var fcssRestoreCmd = &cobra.Command{
+	Use:   "restore <steamid> <snapshot>",
+	Short: "Restore the cluster data of a player from a snapshot",
+	Long: `Restore the cluster data of a player from a snapshot. The current file is
+backed up to --backup-dir first and the player must be offline on every
+server of the config, --force skips the check. --check only reports the
+changes.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(doFCSSRestore(context.Background(), args[0], args[1]))
+	},
+}
+
+// fcssRemoveItemCmd represents the fcss remove-item command
+var fcssRemoveItemCmd = &cobra.Command{
+	Use:   "remove-item <steamid> <item-class>",
+	Short: "Remove an item from the cluster data of a player",
+	Long: `Remove an item from the cluster data of a player. The item class is the full
+class or its short name, e.g. PrimalItemArmor_RiotHelmet. The file is backed
+up to --backup-dir first and the player must be offline on every server of
+the config, --force skips the check. --check only reports the changes.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(doFCSSRemoveItem(context.Background(), args[0], args[1]))
 	},
 }
 
@@ -30,97 +119,615 @@ func init() {
 	cobra.OnInitialize(setDefaults)
 
 	rootCmd.AddCommand(fcssCmd)
+	fcssCmd.AddCommand(fcssListCmd)
+	fcssCmd.AddCommand(fcssShowCmd)
+	fcssCmd.AddCommand(fcssSnapshotCmd)
+	fcssCmd.AddCommand(fcssDiffCmd)
+	fcssCmd.AddCommand(fcssWatchCmd)
+	fcssCmd.AddCommand(fcssRestoreCmd)
+	fcssCmd.AddCommand(fcssRemoveItemCmd)
 
-	fcssCmd.Flags().String("fcss-dir", "gamedata/Clusters/FCSS", "FCSS install dir")
+	fcssCmd.PersistentFlags().String("fcss-dir", "gamedata/Clusters/FCSS", "FCSS install dir")
+	fcssCmd.PersistentFlags().String("snapshot-dir", "gamedata/Clusters/FCSS-snapshots", "dir of the fcss snapshots")
+	fcssCmd.PersistentFlags().String("backup-dir", "gamedata/Clusters/FCSS-backups", "dir of the backups of the edited player files")
+
+	cobra.CheckErr(viper.BindPFlags(fcssCmd.PersistentFlags()))
+
+	// show shares the item filter of list
+	fcssListCmd.Flags().String("survivor", "", "only players whose survivor name contains this")
+	fcssListCmd.Flags().String("tribe", "", "only players whose tribe name contains this")
+	fcssListCmd.Flags().String("item-class", "", "only players and items whose item class contains this")
+	fcssShowCmd.Flags().AddFlag(fcssListCmd.Flags().Lookup("item-class"))
+
+	cobra.CheckErr(viper.BindPFlags(fcssListCmd.Flags()))
+
+	fcssWatchCmd.Flags().Bool("discord", false, "also post the events to discord with the api-token of the chatbot")
+	fcssWatchCmd.Flags().String("discord-channel", "", "discord channel id of the events (default is the channel-id of the config)")
+
+	cobra.CheckErr(viper.BindPFlags(fcssWatchCmd.Flags()))
+
+	fcssRemoveItemCmd.Flags().String("item-id", "", "remove only the item of this id")
+	fcssRemoveItemCmd.Flags().Bool("all", false, "remove every matching item, not only when one matches")
+
+	cobra.CheckErr(viper.BindPFlags(fcssRemoveItemCmd.Flags()))
+}
+
+// playerFilter selects players and items by the fcss filter flags
+type playerFilter struct {
+	survivor  string
+	tribe     string
+	itemClass string
+}
+
+func newPlayerFilter() *playerFilter {
+	return &playerFilter{
+		survivor:  viper.GetString("survivor"),
+		tribe:     viper.GetString("tribe"),
+		itemClass: viper.GetString("item-class"),
+	}
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (filter *playerFilter) match(player *clustersav.Player) bool {
+	if filter.survivor != "" && !containsFold(player.Name(), filter.survivor) {
+		return false
+	}
+	if filter.tribe != "" && !containsFold(player.Tribe(), filter.tribe) {
+		return false
+	}
+	if filter.itemClass != "" && len(filter.items(player)) == 0 {
+		return false
+	}
+	return true
+}
+
+func (filter *playerFilter) items(player *clustersav.Player) (items []*clustersav.Item) {
+	for _, item := range player.Items {
+		if filter.itemClass == "" || containsFold(item.Class, filter.itemClass) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func isCsvFormat() bool {
+	return viper.GetString("format") == "csv"
+}
+
+func encodeJson(v any) (err error) {
+	enc := json.NewEncoder(Output)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return errors.Wrap(err, "json.Encode")
+	}
+	return nil
+}
 
-	cobra.CheckErr(viper.BindPFlags(fcssCmd.Flags()))
+func writeCsv(header []string, rows [][]string) (err error) {
+	w := csv.NewWriter(Output)
+	w.Write(header)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return errors.Wrap(err, "csv.Write")
+	}
+	return nil
 }
 
-func doFCSS(ctx context.Context, args []string) error {
+func writeTable(header []string, rows [][]string) (err error) {
+	w := tabwriter.NewWriter(Output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
 
+// doFCSS prints the JSON data of every player file as one array, as it
+// always did, including the fields the player model does not know
+func doFCSS() (err error) {
 	matches, err := filepath.Glob(filepath.Join(viper.GetString("fcss-dir"), "Players", "*.sav"))
 	if err != nil {
-		return errors.Wrap(err, "filapath.Glob")
+		return errors.Wrap(err, "filepath.Glob")
 	}
 
-	var players []any
-
+	var players [][]byte
 	for _, path := range matches {
-		out, err := ReadSav(path)
+		data, err := clustersav.ReadSav(path)
 		if err != nil {
-			return errors.Wrap(err, "ReadSav")
+			return errors.Wrap(err, "clustersav.ReadSav")
 		}
+		players = append(players, data)
+	}
+	return writeRawPlayers(players)
+}
 
+// writeRawPlayers prints the JSON data of players as one array, the output
+// of the bare fcss command
+func writeRawPlayers(players [][]byte) (err error) {
+	var out []any
+	for _, data := range players {
 		var player any
-		if err := json.Unmarshal(out, &player); err != nil {
+		if err := json.Unmarshal(data, &player); err != nil {
 			return errors.Wrap(err, "json.Unmarshal")
 		}
-		players = append(players, player)
+		out = append(out, player)
 	}
 
-	if err := json.NewEncoder(os.Stdout).Encode(players); err != nil {
+	if err := json.NewEncoder(Output).Encode(out); err != nil {
 		return errors.Wrap(err, "json.Encode")
 	}
-
 	return nil
 }
 
-func readString(data []byte) (s string, nread int) {
-	l := binary.LittleEndian.Uint32(data)
-	data = data[4:]
+func doFCSSList() (err error) {
+	players, err := clustersav.ReadPlayers(viper.GetString("fcss-dir"))
+	if err != nil {
+		return errors.Wrap(err, "clustersav.ReadPlayers")
+	}
+
+	filter := newPlayerFilter()
 
-	s = string(data[:l])
-	data = data[l:]
+	var matched [][]byte
+	var rows [][]string
+	for _, player := range players {
+		if !filter.match(player) {
+			continue
+		}
+		matched = append(matched, player.Data)
 
-	return s, int(l) + 4
+		level := ""
+		if player.Survivor != nil {
+			level = strconv.Itoa(player.Survivor.Level)
+		}
+		rows = append(rows, []string{
+			string(player.SteamId),
+			player.Name(),
+			player.Tribe(),
+			level,
+			strconv.Itoa(len(player.Items)),
+			strconv.Itoa(len(player.Dinos)),
+		})
+	}
+
+	// the data as the mod wrote it, like the bare fcss command
+	if isJsonFormat() {
+		return writeRawPlayers(matched)
+	}
+
+	header := []string{"STEAMID", "SURVIVOR", "TRIBE", "LEVEL", "ITEMS", "DINOS"}
+	if isCsvFormat() {
+		return writeCsv(header, rows)
+	}
+	return writeTable(header, rows)
 }
 
-func ToUtf16le(utf8 []byte) (utf16le []byte, err error) {
-	utf16le, _, err = transform.Bytes(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder(), utf8)
-	return
+// fcssPlayerPath is the file of the player of steamId in the fcss dir
+func fcssPlayerPath(steamId string) (path string, err error) {
+	if steamId == "" || steamId != filepath.Base(steamId) || strings.HasPrefix(steamId, ".") {
+		return "", errors.Errorf("invalid steam id %q", steamId)
+	}
+	return filepath.Join(viper.GetString("fcss-dir"), "Players", steamId+".sav"), nil
 }
 
-func ToUtf8(utf16le []byte) (utf8 []byte, err error) {
-	utf8, _, err = transform.Bytes(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder(), utf16le)
-	return
+// readFCSSPlayer reads the cluster data of the player of steamId
+func readFCSSPlayer(steamId string) (player *clustersav.Player, err error) {
+	path, err := fcssPlayerPath(steamId)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Errorf("no cluster data of %v", steamId)
+		}
+		return nil, errors.Wrap(err, "os.Stat")
+	}
+
+	player, err = clustersav.ReadPlayer(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "clustersav.ReadPlayer")
+	}
+	return player, nil
 }
 
-func ReadSav(filepath string) (out []byte, err error) {
-	data, err := ioutil.ReadFile(filepath)
+func doFCSSShow(steamId string) (err error) {
+	player, err := readFCSSPlayer(steamId)
 	if err != nil {
-		return nil, errors.Wrap(err, "ioutil.ReadFile")
+		return err
+	}
+
+	filter := newPlayerFilter()
+	shown := *player
+	if filter.itemClass != "" {
+		shown.Items = filter.items(player)
+	}
+
+	// the data as the mod wrote it, without the items the filter drops
+	if isJsonFormat() {
+		data := player.Data
+		if filter.itemClass != "" {
+			data, _, err = clustersav.RemoveItems(data, func(item *clustersav.Item) bool {
+				return !containsFold(item.Class, filter.itemClass)
+			})
+			if err != nil {
+				return errors.Wrap(err, "clustersav.RemoveItems")
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		if err := json.Indent(buf, data, "", "  "); err != nil {
+			return errors.Wrap(err, "json.Indent")
+		}
+		buf.WriteByte('\n')
+		_, err = buf.WriteTo(Output)
+		return err
 	}
 
-	var nread int
+	header := []string{"KIND", "CLASS", "NAME", "QUANTITY", "QUALITY", "BLUEPRINT", "LEVEL", "GENDER"}
+	var rows [][]string
+	for _, item := range shown.Items {
+		rows = append(rows, []string{
+			"item",
+			clustersav.ShortClass(item.Class),
+			item.Name,
+			strconv.Itoa(item.Quantity),
+			strconv.FormatFloat(item.Quality, 'f', -1, 64),
+			strconv.FormatBool(item.Blueprint),
+			"",
+			"",
+		})
+	}
+	for _, dino := range shown.Dinos {
+		rows = append(rows, []string{
+			"dino",
+			clustersav.ShortClass(dino.Class),
+			dino.Name,
+			"",
+			"",
+			"",
+			strconv.Itoa(dino.Level),
+			dino.Gender,
+		})
+	}
 
-	_, nread = readString(data)
-	data = data[nread:]
+	if isCsvFormat() {
+		return writeCsv(header, rows)
+	}
 
-	_, nread = readString(data)
-	data = data[nread:]
+	fmt.Fprintf(Output, "SteamId:  %v\n", shown.SteamId)
+	if shown.Survivor != nil {
+		fmt.Fprintf(Output, "Survivor: %v\n", shown.Survivor.Name)
+		fmt.Fprintf(Output, "Tribe:    %v\n", shown.Survivor.Tribe)
+		fmt.Fprintf(Output, "Level:    %v\n", shown.Survivor.Level)
+	}
+	fmt.Fprintln(Output)
+
+	return writeTable(header, rows)
+}
 
-	_, nread = readString(data)
-	data = data[nread:]
+func doFCSSSnapshot() (err error) {
+	snap, err := clustersav.TakeSnapshot(viper.GetString("fcss-dir"))
+	if err != nil {
+		return errors.Wrap(err, "clustersav.TakeSnapshot")
+	}
 
-	length := binary.LittleEndian.Uint64(data)
-	data = data[8:]
+	path, err := snap.Save(viper.GetString("snapshot-dir"))
+	if err != nil {
+		return errors.Wrap(err, "snap.Save")
+	}
 
-	code := data[0:4]
-	data = data[4:]
+	log.Infof("snapshot of %v players: %v", len(snap.Players), path)
+	return nil
+}
 
-	json := data[:length-4]
-	data = data[length-4:]
+// loadFCSSSnapshot loads the snapshot named by a diff argument
+func loadFCSSSnapshot(name string) (snap *clustersav.Snapshot, err error) {
+	dir := viper.GetString("snapshot-dir")
 
-	if code[3] == 255 {
-		utf8, err := ToUtf8(json)
+	switch name {
+	case "current":
+		return clustersav.TakeSnapshot(viper.GetString("fcss-dir"))
+	case "latest":
+		paths, err := clustersav.Snapshots(dir)
 		if err != nil {
-			return nil, errors.Wrap(err, "ToUtf8")
+			return nil, errors.Wrap(err, "clustersav.Snapshots")
+		}
+		if len(paths) == 0 {
+			return nil, errors.Errorf("no snapshot in %v", dir)
 		}
-		out = utf8
-	} else {
-		out = json
+		name = paths[len(paths)-1]
+	}
+
+	for _, path := range []string{name, filepath.Join(dir, name), filepath.Join(dir, name+".json")} {
+		if _, err := os.Stat(path); err == nil {
+			return clustersav.LoadSnapshot(path)
+		}
+	}
+	return nil, errors.Errorf("no snapshot %v", name)
+}
+
+func doFCSSDiff(a, b string) (err error) {
+	before, err := loadFCSSSnapshot(a)
+	if err != nil {
+		return errors.Wrap(err, a)
 	}
 
-	out = bytes.Trim(out, "\x00")
-	return out, nil
+	after, err := loadFCSSSnapshot(b)
+	if err != nil {
+		return errors.Wrap(err, b)
+	}
+
+	diffs, err := clustersav.Diff(before, after)
+	if err != nil {
+		return errors.Wrap(err, "clustersav.Diff")
+	}
+
+	if isJsonFormat() {
+		return encodeJson(diffs)
+	}
+
+	header := []string{"STEAMID", "SURVIVOR", "CHANGE", "KIND", "CLASS", "NAME", "QUANTITY", "LEVEL"}
+	var rows [][]string
+	for _, diff := range diffs {
+		for _, change := range []struct {
+			name  string
+			items []*clustersav.ItemChange
+			dinos []*clustersav.Dino
+		}{
+			{"removed", diff.RemovedItems, diff.RemovedDinos},
+			{"added", diff.AddedItems, diff.AddedDinos},
+		} {
+			for _, item := range change.items {
+				rows = append(rows, []string{
+					diff.SteamId, diff.Survivor, change.name, "item",
+					clustersav.ShortClass(item.Item.Class), item.Item.Name, strconv.Itoa(item.Quantity), "",
+				})
+			}
+			for _, dino := range change.dinos {
+				rows = append(rows, []string{
+					diff.SteamId, diff.Survivor, change.name, "dino",
+					clustersav.ShortClass(dino.Class), dino.Name, "", strconv.Itoa(dino.Level),
+				})
+			}
+		}
+	}
+
+	if isCsvFormat() {
+		return writeCsv(header, rows)
+	}
+
+	fmt.Fprintf(Output, "%v => %v\n\n", before.Time.Local().Format(time.RFC3339), after.Time.Local().Format(time.RFC3339))
+	return writeTable(header, rows)
+}
+
+func doFCSSWatch(ctx context.Context) (err error) {
+	var dg *discordgo.Session
+	channelId := viper.GetString("discord-channel")
+	// channel-id is bound with the placeholder default of the chatbot flag,
+	// only a configured one is a channel
+	if channelId == "" && viper.IsSet("channel-id") {
+		channelId = viper.GetString("channel-id")
+	}
+	if viper.GetBool("discord") {
+		apiToken := viper.GetString("api-token")
+		if apiToken == "" || channelId == "" {
+			return errors.Errorf("--discord needs api-token in the config and --discord-channel or channel-id")
+		}
+
+		dg, err = discordgo.New("Bot " + apiToken)
+		if err != nil {
+			return errors.Wrap(err, "discordgo.New")
+		}
+	}
+
+	enc := json.NewEncoder(Output)
+	err = clustersav.Watch(ctx, viper.GetString("fcss-dir"), func(event *clustersav.Event) {
+		if err := enc.Encode(event); err != nil {
+			log.Errorf("json.Encode failure: %v", err)
+		}
+
+		if dg == nil {
+			return
+		}
+
+		if _, err := dg.ChannelMessageSendComplex(channelId, &discordgo.MessageSend{
+			Content:         event.String(),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		}); err != nil {
+			log.Errorf("dg.ChannelMessageSend failure: %v", err)
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return errors.Wrap(err, "clustersav.Watch")
+	}
+	return nil
+}
+
+// checkOffline fails unless the player of steamId is offline on every
+// server of the config, or of rcon-addr
+func checkOffline(ctx context.Context, steamId string) (err error) {
+	var servers []*rcon.Server
+	if err := viper.UnmarshalKey("servers", &servers); err != nil {
+		return errors.Wrap(err, "viper.UnmarshalKey(servers)")
+	}
+	if addr := viper.GetString("rcon-addr"); addr != "" {
+		servers = append(servers, &rcon.Server{Name: addr, RconAddr: addr, Password: viper.GetString("password")})
+	}
+
+	if len(servers) == 0 {
+		return errors.Errorf("no servers in the config to check that %v is offline (--force skips the check)", steamId)
+	}
+
+	for _, server := range servers {
+		players, err := listPlayers(ctx, server)
+		if err != nil {
+			return errors.Wrapf(err, "cannot check that %v is offline on %v (--force skips the check)", steamId, server.Name)
+		}
+
+		if name, ok := players[steamId]; ok {
+			return errors.Errorf("%v (%v) is online on %v", steamId, name, server.Name)
+		}
+	}
+	return nil
+}
+
+func listPlayers(ctx context.Context, server *rcon.Server) (players map[string]string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return server.ListPlayers(ctx)
+}
+
+// backupPlayerFile copies the file at path to the backup dir, when it exists
+func backupPlayerFile(path string) (err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "ioutil.ReadFile")
+	}
+
+	dir := viper.GetString("backup-dir")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "os.MkdirAll")
+	}
+
+	// a backup may be the only copy of the original file, it is never
+	// overwritten, not even by an edit in the same second
+	name := strings.TrimSuffix(filepath.Base(path), ".sav")
+	backup := filepath.Join(dir, fmt.Sprintf("%v-%v.sav", name, time.Now().UTC().Format("20060102-150405.000000000")))
+	f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Wrap(err, "os.OpenFile")
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Write")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	log.Infof("backup of %v: %v", path, backup)
+	return nil
+}
+
+// writePlayerFile replaces the file of the player of steamId with the
+// encoded file data, after reporting the changes, checking that the player
+// is offline and backing the file up. In check mode it only reports the
+// changes.
+func writePlayerFile(ctx context.Context, steamId, path string, before *clustersav.Player, data []byte) (err error) {
+	sav, err := clustersav.Parse(data)
+	if err != nil {
+		return errors.Wrap(err, "clustersav.Parse")
+	}
+
+	after, err := clustersav.Decode(sav.Data)
+	if err != nil {
+		return errors.Wrap(err, "clustersav.Decode")
+	}
+
+	events := clustersav.Events(before, after)
+	if len(events) == 0 {
+		log.Infof("%v: no changes", path)
+	}
+	for _, event := range events {
+		log.Infof("%v: %v", path, event)
+	}
+
+	if viper.GetBool("check") {
+		return nil
+	}
+
+	if !viper.GetBool("force") {
+		if err := checkOffline(ctx, steamId); err != nil {
+			return err
+		}
+	}
+
+	if err := backupPlayerFile(path); err != nil {
+		return errors.Wrap(err, "backupPlayerFile")
+	}
+
+	if err := clustersav.WriteFile(path, data); err != nil {
+		return errors.Wrap(err, "clustersav.WriteFile")
+	}
+	return nil
+}
+
+func doFCSSRestore(ctx context.Context, steamId, name string) (err error) {
+	snap, err := loadFCSSSnapshot(name)
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+
+	restored := snap.Player(steamId)
+	if restored == nil {
+		return errors.Errorf("no cluster data of %v in the snapshot of %v", steamId, snap.Time.Local().Format(time.RFC3339))
+	}
+
+	// the file name of the snapshot is not trusted, a crafted one could
+	// point out of the Players dir
+	path, err := fcssPlayerPath(steamId)
+	if err != nil {
+		return err
+	}
+
+	before := &clustersav.Player{}
+	if _, err := os.Stat(path); err == nil {
+		if before, err = clustersav.ReadPlayer(path); err != nil {
+			log.Warnf("current data is unreadable, restore anyway: %v", err)
+			before = &clustersav.Player{}
+		}
+	}
+
+	return writePlayerFile(ctx, steamId, path, before, restored.Raw)
+}
+
+func doFCSSRemoveItem(ctx context.Context, steamId, class string) (err error) {
+	path, err := fcssPlayerPath(steamId)
+	if err != nil {
+		return err
+	}
+
+	sav, err := clustersav.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "clustersav.ReadFile")
+	}
+
+	before, err := clustersav.Decode(sav.Data)
+	if err != nil {
+		return errors.Wrap(err, "clustersav.Decode")
+	}
+
+	itemId := viper.GetString("item-id")
+	data, removed, err := clustersav.RemoveItems(sav.Data, func(item *clustersav.Item) bool {
+		if !strings.EqualFold(item.Class, class) && !strings.EqualFold(clustersav.ShortClass(item.Class), class) {
+			return false
+		}
+		return itemId == "" || string(item.Id) == itemId
+	})
+	if err != nil {
+		return errors.Wrap(err, "clustersav.RemoveItems")
+	}
+
+	switch {
+	case len(removed) == 0:
+		return errors.Errorf("%v has no item %v", steamId, class)
+	case len(removed) > 1 && !viper.GetBool("all"):
+		return errors.Errorf("%v items %v match, --all removes all of them or --item-id selects one", len(removed), class)
+	}
+
+	sav.Data = data
+	encoded, err := sav.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "sav.Bytes")
+	}
+	return writePlayerFile(ctx, steamId, path, before, encoded)
 }
@@ -0,0 +1,40 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/jeehoon/arktools/pkg/lock"
+	"github.com/jeehoon/arktools/pkg/log"
+)
+
+// lockInstallDir keeps other arktools processes from changing the install dir
+// until release is called
+func lockInstallDir(ctx context.Context) (release func(), err error) {
+	installDir := viper.GetString("install-dir")
+	wait := viper.GetBool("wait")
+
+	if err := os.MkdirAll(installDir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "os.MkdirAll(%v)", installDir)
+	}
+
+	path := filepath.Join(installDir, ".arktools.lock")
+	l, err := lock.Acquire(ctx, path, strings.Join(os.Args, " "), wait)
+	if err != nil {
+		return nil, errors.Wrap(err, "lock.Acquire")
+	}
+
+	return func() {
+		if err := l.Release(); err != nil {
+			log.Warnf("lock.Release failure: %v", err)
+		}
+	}, nil
+}
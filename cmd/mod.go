@@ -0,0 +1,119 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jeehoon/arktools/pkg/steamcmd"
+)
+
+// modCmd represents the mod command
+var modCmd = &cobra.Command{
+	Use:   "mod",
+	Short: "Manage installed ARK Mods",
+}
+
+// modVerifyCmd represents the mod verify command
+var modVerifyCmd = &cobra.Command{
+	Use:   "verify [modid...]",
+	Short: "Verify installed ARK Mods (all installed mods if no modid is given)",
+	Long: `Verify installed ARK Mods (all installed mods if no modid is given). The
+files of a mod are checked against the sizes of the workshop download, as
+recorded in its .yaml when arktools installed it. Mods installed by an earlier
+version have no such list and only their .mod, .yaml and leftover compressed
+files are checked; --repair reinstalls them to record it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		var modIds []int
+		for _, arg := range args {
+			i, err := strconv.ParseInt(arg, 10, 64)
+			cobra.CheckErr(err)
+
+			modIds = append(modIds, int(i))
+		}
+
+		cobra.CheckErr(doVerifyMods(ctx, modIds))
+	},
+}
+
+func init() {
+	cobra.OnInitialize(setDefaults)
+
+	rootCmd.AddCommand(modCmd)
+	modCmd.AddCommand(modVerifyCmd)
+
+	modVerifyCmd.Flags().Bool("repair", false, "reinstall broken mods and those without a recorded file list")
+
+	cobra.CheckErr(viper.BindPFlags(modVerifyCmd.Flags()))
+}
+
+func doVerifyMods(ctx context.Context, modIds []int) (err error) {
+	installDir := viper.GetString("install-dir")
+	steamcmdExec := viper.GetString("steamcmd")
+	modAppId := viper.GetInt("mod-appid")
+	repair := viper.GetBool("repair")
+	force := viper.GetBool("force")
+
+	// only --repair writes to the install dir
+	if repair {
+		release, err := lockInstallDir(ctx)
+		if err != nil {
+			return errors.Wrap(err, "lockInstallDir")
+		}
+		defer release()
+	}
+
+	scmd := steamcmd.NewSteamCmd(steamcmdExec, installDir)
+	scmd.SetOutput(Output)
+
+	if len(modIds) == 0 {
+		modIds, err = scmd.InstalledMods()
+		if err != nil {
+			return errors.Wrap(err, "scmd.InstalledMods")
+		}
+	}
+
+	checks, err := scmd.VerifyMods(ctx, modAppId, modIds)
+	if err != nil {
+		return errors.Wrapf(err, "scmd.VerifyMods(%v)", modAppId)
+	}
+
+	var broken, reinstall []int
+	for _, check := range checks {
+		if !check.Ok() {
+			broken = append(broken, check.ModId)
+		}
+		if !check.Ok() || check.NoFiles {
+			reinstall = append(reinstall, check.ModId)
+		}
+	}
+
+	if !repair {
+		if len(broken) > 0 {
+			return errors.Errorf("%v mods are broken: %v", len(broken), broken)
+		}
+		return nil
+	}
+
+	if len(reinstall) == 0 {
+		return nil
+	}
+
+	if err := preflight(scmd.CheckModsSpace(ctx, reinstall), force); err != nil {
+		return errors.Wrapf(err, "scmd.CheckModsSpace(%v)", reinstall)
+	}
+
+	if _, err := scmd.UpdateMods(ctx, modAppId, reinstall); err != nil {
+		return errors.Wrapf(err, "steamcmd.UpdateMods(%v, %v)", modAppId, reinstall)
+	}
+
+	return nil
+}
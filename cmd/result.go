@@ -0,0 +1,130 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/jeehoon/arktools/pkg/steamcmd"
+)
+
+// update actions
+const (
+	ActionNone      = "none"
+	ActionAvailable = "available"
+	ActionUpdated   = "updated"
+	ActionFailed    = "failed"
+)
+
+// exit codes of update commands
+const (
+	ExitUpToDate  = 0
+	ExitFailed    = 1
+	ExitUpdated   = 2
+	ExitAvailable = 3
+)
+
+type ServerResult struct {
+	AppId         int               `json:"appid"`
+	CurrentBuild  string            `json:"current_build,omitempty"`
+	RemoteBuild   string            `json:"remote_build,omitempty"`
+	CurrentDepots map[string]string `json:"current_depots,omitempty"`
+	RemoteDepots  map[string]string `json:"remote_depots,omitempty"`
+	Action        string            `json:"action"`
+	Error         string            `json:"error,omitempty"`
+}
+
+type ModResult struct {
+	Id       int    `json:"id"`
+	Title    string `json:"title"`
+	Local    int    `json:"local"`
+	Remote   int    `json:"remote"`
+	Requires []int  `json:"requires,omitempty"`
+	Action   string `json:"action"`
+	Error    string `json:"error,omitempty"`
+}
+
+type UpdateResult struct {
+	Server *ServerResult `json:"server,omitempty"`
+	Mods   []*ModResult  `json:"mods,omitempty"`
+	// DepsGraph is the mod dependency graph of --deps-graph
+	DepsGraph []*steamcmd.ModNode `json:"deps_graph,omitempty"`
+	// DepsUnresolved is whether the mod requirements are unknown, without
+	// a steam api key
+	DepsUnresolved bool   `json:"deps_unresolved,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+func (result *UpdateResult) actions() (actions []string) {
+	if result.Server != nil {
+		actions = append(actions, result.Server.Action)
+	}
+	for _, mod := range result.Mods {
+		actions = append(actions, mod.Action)
+	}
+	return actions
+}
+
+func (result *UpdateResult) exitCode() int {
+	code := ExitUpToDate
+	for _, action := range result.actions() {
+		switch action {
+		case ActionFailed:
+			return ExitFailed
+		case ActionUpdated:
+			code = ExitUpdated
+		case ActionAvailable:
+			if code == ExitUpToDate {
+				code = ExitAvailable
+			}
+		}
+	}
+	return code
+}
+
+// checkUpdateFormat rejects the formats update commands do not print
+func checkUpdateFormat() (err error) {
+	switch format := viper.GetString("format"); format {
+	case "text", "json":
+		return nil
+	default:
+		return errors.Errorf("--format %v is not supported by update commands (text, json)", format)
+	}
+}
+
+func isJsonFormat() bool {
+	return viper.GetString("format") == "json"
+}
+
+// userOutput is where steamcmd reports progress lines; they are suppressed
+// when the result is printed as json, which holds what they report
+func userOutput() io.Writer {
+	if isJsonFormat() {
+		return nil
+	}
+	return Output
+}
+
+// reportUpdate prints the result of an update command and exits
+func reportUpdate(result *UpdateResult, err error) {
+	if err != nil && result.exitCode() != ExitFailed {
+		result.Error = err.Error()
+	}
+
+	if isJsonFormat() {
+		enc := json.NewEncoder(Output)
+		enc.SetIndent("", "  ")
+		cobra.CheckErr(enc.Encode(result))
+	}
+
+	cobra.CheckErr(err)
+
+	os.Exit(result.exitCode())
+}
@@ -45,9 +45,12 @@ func init() {
 
 	rootCmd.PersistentFlags().String("install-dir", "", "ARK server install dir (default is $HOME/ARK)")
 	rootCmd.PersistentFlags().String("steamcmd", "", "SteamCMD location (default is $HOME/steamcmd/steamcmd.sh)")
+	rootCmd.PersistentFlags().String("steam-api-key", "", "Steam Web API key (required to resolve mod dependencies)")
 	rootCmd.PersistentFlags().BoolP("check", "c", false, "check mode")
 	rootCmd.PersistentFlags().BoolP("force", "f", false, "force mode")
+	rootCmd.PersistentFlags().Bool("wait", false, "wait for other arktools commands on the install dir to finish")
 	rootCmd.PersistentFlags().String("output", "stdout", "output execution result")
+	rootCmd.PersistentFlags().String("format", "text", "output format (text, json, csv of fcss)")
 
 	cobra.CheckErr(viper.BindPFlags(rootCmd.PersistentFlags()))
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/jeehoon/arktools/pkg/log"
 	"github.com/jeehoon/arktools/pkg/steamcmd"
 )
 
@@ -17,9 +18,13 @@ import (
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update ARK Server",
+	Long: `Update ARK Server. The exit code is 0 when up-to-date, 1 on failure, 2 when
+updated and 3 when an update is available in check mode.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(checkUpdateFormat())
+
 		ctx := context.Background()
-		cobra.CheckErr(doUpdate(ctx))
+		reportUpdate(doUpdate(ctx))
 	},
 }
 
@@ -33,7 +38,8 @@ func init() {
 	cobra.CheckErr(viper.BindPFlags(updateCmd.Flags()))
 }
 
-func doUpdate(ctx context.Context) (err error) {
+func doUpdate(ctx context.Context) (result *UpdateResult, err error) {
+	result = new(UpdateResult)
 
 	installDir := viper.GetString("install-dir")
 	steamcmdExec := viper.GetString("steamcmd")
@@ -41,25 +47,65 @@ func doUpdate(ctx context.Context) (err error) {
 	check := viper.GetBool("check")
 	force := viper.GetBool("force")
 
+	// --check only reads the install dir
+	if !check {
+		release, err := lockInstallDir(ctx)
+		if err != nil {
+			return result, errors.Wrap(err, "lockInstallDir")
+		}
+		defer release()
+	}
+
 	scmd := steamcmd.NewSteamCmd(steamcmdExec, installDir)
-	scmd.SetOutput(Output)
+	scmd.SetOutput(userOutput())
 
 	// server
-	var hasUpdate bool
-	if force {
-		hasUpdate = true
-	} else {
-		hasUpdate, err = scmd.HasUpdate(ctx, appId)
+	server := &ServerResult{AppId: appId, Action: ActionFailed}
+	result.Server = server
+
+	defer func() {
 		if err != nil {
-			return errors.Wrapf(err, "scmd.HasUpdate(%v)", appId)
+			server.Action = ActionFailed
+			server.Error = err.Error()
 		}
+	}()
+
+	status, err := scmd.ServerStatus(ctx, appId)
+	if err != nil {
+		return result, errors.Wrapf(err, "scmd.ServerStatus(%v)", appId)
 	}
+	server.CurrentBuild = status.LocalBuild
+	server.RemoteBuild = status.RemoteBuild
+	server.CurrentDepots = status.LocalDepots
+	server.RemoteDepots = status.RemoteDepots
+
+	hasUpdate := force || status.HasUpdate
+
+	switch {
+	case !hasUpdate:
+		server.Action = ActionNone
+	case check:
+		server.Action = ActionAvailable
+	default:
+		if err := preflight(scmd.CheckServerSpace(ctx, appId), force); err != nil {
+			return result, errors.Wrapf(err, "scmd.CheckServerSpace(%v)", appId)
+		}
 
-	if hasUpdate && !check {
 		if err := scmd.UpdateServer(ctx, appId); err != nil {
-			return errors.Wrapf(err, "steamcmd.UpdateServer(%v)", appId)
+			return result, errors.Wrapf(err, "steamcmd.UpdateServer(%v)", appId)
 		}
+		server.Action = ActionUpdated
 	}
 
-	return nil
+	return result, nil
+}
+
+// preflight turns an insufficient disk space error into a warning under --force
+func preflight(err error, force bool) error {
+	var spaceErr *steamcmd.InsufficientSpaceError
+	if err != nil && force && errors.As(err, &spaceErr) {
+		log.Warnf("%v (ignored by --force)", err)
+		return nil
+	}
+	return err
 }
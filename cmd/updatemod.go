@@ -18,7 +18,11 @@ import (
 var updatemodCmd = &cobra.Command{
 	Use:   "updatemod",
 	Short: "Update ARK Server",
+	Long: `Update ARK Mods. The exit code is 0 when up-to-date, 1 on failure, 2 when
+updated and 3 when an update is available in check mode.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(checkUpdateFormat())
+
 		ctx := context.Background()
 
 		var modIds []int
@@ -29,7 +33,7 @@ var updatemodCmd = &cobra.Command{
 			modIds = append(modIds, int(i))
 		}
 
-		cobra.CheckErr(doUpdateMods(ctx, modIds))
+		reportUpdate(doUpdateMods(ctx, modIds))
 	},
 }
 
@@ -41,37 +45,108 @@ func init() {
 	// for mod updatemod
 	updatemodCmd.Flags().Int("mod-appid", 346110, "ARK Mod AppId")
 	updatemodCmd.Flags().IntSlice("modids", nil, "modid list, comma separated string")
+	updatemodCmd.Flags().Bool("resolve-deps", true, "download mods required by the given mods (requires --steam-api-key)")
+	updatemodCmd.Flags().Bool("deps-graph", false, "print mod dependency graph")
 
 	cobra.CheckErr(viper.BindPFlags(updatemodCmd.Flags()))
 }
 
-func doUpdateMods(ctx context.Context, modIds []int) (err error) {
+func doUpdateMods(ctx context.Context, modIds []int) (result *UpdateResult, err error) {
+	result = new(UpdateResult)
 
 	installDir := viper.GetString("install-dir")
 	steamcmdExec := viper.GetString("steamcmd")
 	modAppId := viper.GetInt("mod-appid")
 	check := viper.GetBool("check")
 	force := viper.GetBool("force")
+	resolveDeps := viper.GetBool("resolve-deps")
+	depsGraph := viper.GetBool("deps-graph")
+
+	// --check only reads the install dir
+	if !check {
+		release, err := lockInstallDir(ctx)
+		if err != nil {
+			return result, errors.Wrap(err, "lockInstallDir")
+		}
+		defer release()
+	}
 
 	scmd := steamcmd.NewSteamCmd(steamcmdExec, installDir)
-	scmd.SetOutput(Output)
+	scmd.SetOutput(userOutput())
+	scmd.SetApiKey(viper.GetString("steam-api-key"))
+
+	// dependencies
+	var requires map[int][]int
+	if resolveDeps || depsGraph {
+		deps, err := scmd.ResolveModDependencies(ctx, modIds)
+		if err != nil {
+			return result, errors.Wrap(err, "scmd.ResolveModDependencies")
+		}
+		result.DepsUnresolved = deps.Unresolved
+
+		if depsGraph {
+			result.DepsGraph = deps.Graph()
+			scmd.PrintModDependencies(deps)
+		}
+
+		if resolveDeps {
+			modIds = deps.Order
+		}
+		requires = deps.Requires
+	}
 
 	// mods
+	statuses, err := scmd.ModsStatus(ctx, modAppId, modIds)
+	if err != nil {
+		return result, errors.Wrapf(err, "scmd.ModsStatus(%v)", modAppId)
+	}
+
+	mods := map[int]*ModResult{}
 	var updatedModids []int
-	if force {
-		updatedModids = modIds
-	} else {
-		updatedModids, err = scmd.UpdateRequiredMods(ctx, modAppId, modIds)
-		if err != nil {
-			return errors.Wrapf(err, "scmd.UpdateRequiredMods(%v)", modAppId)
+	for _, status := range statuses {
+		mod := &ModResult{
+			Id:       status.ModId,
+			Title:    status.Title,
+			Local:    status.Local,
+			Remote:   status.Remote,
+			Requires: requires[status.ModId],
+			Action:   ActionNone,
+		}
+		mods[mod.Id] = mod
+		result.Mods = append(result.Mods, mod)
+
+		if force || status.HasUpdate {
+			updatedModids = append(updatedModids, status.ModId)
+			mod.Action = ActionAvailable
 		}
 	}
 
 	if len(updatedModids) > 0 && !check {
-		if err := scmd.UpdateMods(ctx, modAppId, updatedModids); err != nil {
-			return errors.Wrapf(err, "steamcmd.UpdateMods(%v, %v)", modAppId, updatedModids)
+		var updated []int
+		defer func() {
+			done := map[int]bool{}
+			for _, modId := range updated {
+				done[modId] = true
+			}
+			for _, modId := range updatedModids {
+				if done[modId] {
+					mods[modId].Action = ActionUpdated
+				} else if err != nil {
+					mods[modId].Action = ActionFailed
+					mods[modId].Error = err.Error()
+				}
+			}
+		}()
+
+		if err := preflight(scmd.CheckModsSpace(ctx, updatedModids), force); err != nil {
+			return result, errors.Wrapf(err, "scmd.CheckModsSpace(%v)", updatedModids)
+		}
+
+		updated, err = scmd.UpdateMods(ctx, modAppId, updatedModids)
+		if err != nil {
+			return result, errors.Wrapf(err, "steamcmd.UpdateMods(%v, %v)", modAppId, updatedModids)
 		}
 	}
 
-	return nil
+	return result, nil
 }
@@ -0,0 +1,107 @@
+package chatbot
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jeehoon/arktools/pkg/chatlog"
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// chatlogLimit is the number of entries /chatlog replies with
+const chatlogLimit = 20
+
+// SetArchive records every message the bot relays, in both directions, to
+// archive and enables the /chatlog slash command
+func (cb *ChatBot) SetArchive(archive *chatlog.Archive) {
+	cb.archive = archive
+}
+
+// recordGame archives an in-game message once per cluster, on the first
+// route of the cluster. The messages of the bridges are archived as received.
+func (cb *ChatBot) recordGame(route *Route, msg *RedisMessage) {
+	if cb.archive == nil || cb.bridges[strings.ToLower(msg.ServerName)] != nil {
+		return
+	}
+
+	for _, r := range cb.routes {
+		if r.Cluster == route.Cluster {
+			if r != route {
+				return
+			}
+			break
+		}
+	}
+
+	sec, frac := math.Modf(msg.Epoch)
+	cb.record(&chatlog.Entry{
+		Time:      time.Unix(int64(sec), int64(frac*1e9)).UTC(),
+		Direction: chatlog.FromGame,
+		Cluster:   route.Cluster,
+		Server:    msg.ServerName,
+		Tribe:     msg.TribeName,
+		Survivor:  msg.SurvivorName,
+		Message:   msg.Message,
+	})
+}
+
+func (cb *ChatBot) recordBridge(route *Route, msg *BridgeMessage) {
+	if cb.archive == nil {
+		return
+	}
+
+	cb.record(&chatlog.Entry{
+		Time:      time.Now().UTC(),
+		Direction: strings.ToLower(msg.Bridge),
+		Cluster:   route.Cluster,
+		Server:    msg.Bridge,
+		Tribe:     msg.Tribe,
+		Survivor:  msg.Player,
+		Message:   msg.Content,
+	})
+}
+
+func (cb *ChatBot) record(entry *chatlog.Entry) {
+	if err := cb.archive.Append(entry); err != nil {
+		log.Errorf("archive.Append failure: %v", err)
+	}
+}
+
+func (cb *ChatBot) chatlog(user *discordgo.User, opts map[string]string) (content string, err error) {
+	// discord may still show the command registered by an earlier config
+	if cb.archive == nil {
+		return "", errors.Errorf("the chat archive is disabled")
+	}
+
+	since := 24 * time.Hour
+	if opts["since"] != "" {
+		since, err = time.ParseDuration(opts["since"])
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid since %q", opts["since"])
+		}
+	}
+
+	entries, err := cb.archive.Search(&chatlog.Query{
+		Player:   opts["player"],
+		Contains: opts["contains"],
+		Since:    time.Now().Add(-since),
+		Limit:    chatlogLimit,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "archive.Search")
+	}
+
+	if len(entries) == 0 {
+		return "No messages found.", nil
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, entry.String())
+	}
+
+	return "```\n" + strings.Join(lines, "\n") + "\n```", nil
+}
@@ -0,0 +1,100 @@
+package chatbot
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Bridge is a chat platform the cluster chat is relayed to
+type Bridge interface {
+	// Name is the bridge name of the routes, also the server name of its
+	// messages in the game
+	Name() string
+
+	// Open connects to the platform and calls recv for every message posted
+	// in the channels of routes until ctx is done or Close is called
+	Open(ctx context.Context, routes []*Route, recv func(msg *BridgeMessage)) (err error)
+	Close() (err error)
+
+	// Send relays the leading in-game messages of msgs to the channel of the
+	// route as one platform message. It returns how many messages it took,
+	// at least one, also on failure.
+	Send(ctx context.Context, route *Route, msgs []*RedisMessage) (n int, err error)
+
+	// Retain deletes the messages of the channel of the route older than its
+	// retention, when the platform allows
+	Retain(ctx context.Context, route *Route) (err error)
+}
+
+// BridgeMessage is a message posted on a bridge
+type BridgeMessage struct {
+	Bridge    string
+	ChannelId string
+	// UserId is the user on the bridge, the discord user id or irc nick
+	UserId  string
+	Tribe   string
+	Player  string
+	Content string
+}
+
+// bridge types of BridgeConfig
+const (
+	BridgeHttp = "http"
+	BridgeIrc  = "irc"
+)
+
+// BridgeConfig configures a bridge other than discord
+type BridgeConfig struct {
+	Name string `mapstructure:"name"`
+	// Type is BridgeHttp or BridgeIrc
+	Type string `mapstructure:"type"`
+
+	// URL receives the in-game messages as json (http)
+	URL string `mapstructure:"url"`
+	// Listen is the address of the incoming messages endpoint (http)
+	Listen string `mapstructure:"listen"`
+	// Secret is the bearer token of both directions, required with Listen
+	// (http)
+	Secret string `mapstructure:"secret"`
+
+	// Addr is the server address, host:port (irc)
+	Addr string `mapstructure:"addr"`
+	// Nick is the nickname of the bot (irc)
+	Nick string `mapstructure:"nick"`
+	// Password is the server password (irc)
+	Password string `mapstructure:"password"`
+	// TLS connects over TLS (irc)
+	TLS bool `mapstructure:"tls"`
+}
+
+func NewBridge(config *BridgeConfig) (bridge Bridge, err error) {
+	if config.Name == "" {
+		return nil, errors.Errorf("bridge name is empty")
+	}
+
+	switch strings.ToLower(config.Type) {
+	case BridgeHttp:
+		if config.URL == "" && config.Listen == "" {
+			return nil, errors.Errorf("bridge %v: neither url nor listen is set", config.Name)
+		}
+		// anyone reaching the port could chat in game as any player
+		if config.Listen != "" && config.Secret == "" {
+			return nil, errors.Errorf("bridge %v: listen needs a secret", config.Name)
+		}
+		return NewHttpBridge(config.Name, config.URL, config.Listen, config.Secret), nil
+	case BridgeIrc:
+		if config.Addr == "" {
+			return nil, errors.Errorf("bridge %v: addr is empty", config.Name)
+		}
+		var tlsConfig *tls.Config
+		if config.TLS {
+			tlsConfig = &tls.Config{}
+		}
+		return NewIrc(config.Name, config.Addr, config.Nick, config.Password, tlsConfig), nil
+	default:
+		return nil, errors.Errorf("bridge %v: unknown type: %v", config.Name, config.Type)
+	}
+}
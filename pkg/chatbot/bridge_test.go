@@ -0,0 +1,38 @@
+package chatbot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBridge(t *testing.T) {
+	for _, tc := range []struct {
+		config *BridgeConfig
+		err    string
+	}{
+		{config: &BridgeConfig{Name: "web", Type: "http", URL: "http://localhost/chat"}},
+		{config: &BridgeConfig{Name: "web", Type: "HTTP", Listen: ":8080", Secret: "s"}},
+		{config: &BridgeConfig{Name: "net", Type: "irc", Addr: "irc.example.org:6697", TLS: true}},
+		{config: &BridgeConfig{Type: "http", URL: "http://localhost/chat"}, err: "name is empty"},
+		{config: &BridgeConfig{Name: "web", Type: "http"}, err: "neither url nor listen"},
+		{config: &BridgeConfig{Name: "web", Type: "http", Listen: ":8080"}, err: "needs a secret"},
+		{config: &BridgeConfig{Name: "net", Type: "irc"}, err: "addr is empty"},
+		{config: &BridgeConfig{Name: "x", Type: "telegram"}, err: "unknown type"},
+	} {
+		bridge, err := NewBridge(tc.config)
+		if tc.err != "" {
+			if assert.NotNil(t, err, tc.config.Name) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tc.config.Name, bridge.Name())
+	}
+
+	bridge, err := NewBridge(&BridgeConfig{Name: "net", Type: "irc", Addr: "irc.example.org:6697", TLS: true})
+	assert.Nil(t, err)
+	assert.NotNil(t, bridge.(*Irc).tlsConfig)
+	assert.Equal(t, "arktools", bridge.(*Irc).nick)
+}
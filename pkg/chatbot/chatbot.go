@@ -4,248 +4,326 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
-	"os"
-	"os/signal"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
-	"github.com/bwmarrin/discordgo"
+	"github.com/jeehoon/arktools/pkg/chatlog"
 	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/jeehoon/arktools/pkg/rcon"
 	"github.com/pkg/errors"
 )
 
+// delivery modes of in-game messages, per route
+const (
+	// DeliveryPoll polls the cluster chat list with LRANGE
+	DeliveryPoll = "poll"
+	// DeliverySubscribe receives messages the game servers PUBLISH on the chat
+	// channel. Redis pub/sub drops what is published while the bot is
+	// disconnected, the list checks catch those up.
+	DeliverySubscribe = "subscribe"
+	// DeliveryQueue pops the messages the game servers LPUSH on the queue list
+	// of the route with BRPOP, each exactly once, also those pushed while the
+	// bot was down. Every consumer needs its own list.
+	DeliveryQueue = "queue"
+)
+
 type ChatBot struct {
-	apiToken   string
-	webdisAddr string
-	clusterId  string
-	channelId  string
-	format     string
-
-	client *http.Client
-	dg     *discordgo.Session
+	routes  []*Route
+	bridges map[string]Bridge
+
+	guildId    string
+	servers    []*rcon.Server
+	adminRoles []string
+	auditLog   string
+	auditMu    sync.Mutex
+
+	cursors    *cursorStore
+	catchupMax int
+
+	archive    *chatlog.Archive
+	moderator  *moderator
+	identities *identityStore
+
+	eventsInterval time.Duration
+
+	outboxes map[*Route]*outbox
+
+	health     *health
+	healthAddr string
+
+	transport Transport
 }
 
-func New(discordApiToken, webdisAddr, clusterId, channelId, format string) *ChatBot {
-	if format == "" {
-		format = "```md\n[%v][%v][%v]: %v\n```"
+func New(transport Transport, routes []*Route) *ChatBot {
+	cb := new(ChatBot)
+	cb.bridges = map[string]Bridge{}
+	cb.outboxes = map[*Route]*outbox{}
+
+	for _, route := range routes {
+		route.setDefaults()
+		cb.outboxes[route] = newOutbox(route)
 	}
 
-	cb := new(ChatBot)
-	cb.apiToken = discordApiToken
-	cb.webdisAddr = webdisAddr
-	cb.clusterId = clusterId
-	cb.channelId = channelId
-	cb.format = format
+	cb.routes = routes
+	cb.health = newHealth(routes)
+
+	cb.cursors = newCursorStore("")
+	cb.catchupMax = 10
+	cb.eventsInterval = 30 * time.Second
 
-	cb.client = new(http.Client)
+	cb.transport = transport
 	return cb
 }
 
+// AddBridge relays the cluster chat to the routes of the bridge
+func (cb *ChatBot) AddBridge(bridge Bridge) {
+	cb.bridges[strings.ToLower(bridge.Name())] = bridge
+}
+
+func (cb *ChatBot) bridge(route *Route) Bridge {
+	return cb.bridges[strings.ToLower(route.Bridge)]
+}
+
+// SetState persists the forwarding cursor of each route to stateFile. On
+// restart up to catchupMax messages sent while the bot was down are forwarded.
+func (cb *ChatBot) SetState(stateFile string, catchupMax int) {
+	cb.cursors = newCursorStore(stateFile)
+	cb.catchupMax = catchupMax
+}
+
 func (cb *ChatBot) Serve(ctx context.Context) (err error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	cb.dg, err = discordgo.New("Bot " + cb.apiToken)
-	if err != nil {
-		return errors.Wrap(err, "discordgo.New")
+	if len(cb.routes) == 0 {
+		return errors.Errorf("no route")
 	}
-	cb.dg.AddHandler(cb.MessageHandler)
-	cb.dg.Identify.Intents = discordgo.IntentGuildMessages
 
-	if err := cb.dg.Open(); err != nil {
-		return errors.Wrap(err, "dg.Open")
+	for _, route := range cb.routes {
+		if err := route.validate(); err != nil {
+			return errors.Wrap(err, "route.validate")
+		}
+
+		if cb.bridge(route) == nil {
+			return errors.Errorf("route %v: unknown bridge: %v", route, route.Bridge)
+		}
+	}
+
+	if err := cb.cursors.load(); err != nil {
+		return errors.Wrap(err, "cursors.load")
+	}
+	// after the deliveries stopped, the cursors they set last are written
+	defer func() {
+		if err := cb.cursors.flush(); err != nil {
+			log.Errorf("cursors.flush failure: %v", err)
+		}
+	}()
+
+	if cb.healthAddr != "" {
+		if err := cb.serveHealth(ctx); err != nil {
+			return errors.Wrap(err, "cb.serveHealth")
+		}
+	}
+
+	for _, bridge := range cb.bridges {
+		if d, ok := bridge.(*Discord); ok {
+			d.handlers = append(d.handlers, cb.InteractionHandler)
+			d.onOpen = append(d.onOpen, cb.registerCommands)
+		}
+
+		var routes []*Route
+		for _, route := range cb.routes {
+			if cb.bridge(route) == bridge {
+				routes = append(routes, route)
+			}
+		}
+
+		retry := newBackoff(time.Second, backoffMax)
+		for {
+			err := bridge.Open(ctx, routes, cb.receive)
+			if err == nil {
+				break
+			}
+			log.Errorf("%v.Open failure, retry in %v: %v", bridge.Name(), retry.next, err)
+
+			if err := retry.wait(ctx); err != nil {
+				return err
+			}
+		}
+		defer bridge.Close()
 	}
 
+	log.Infof("Bot is now running")
+
 	var wg sync.WaitGroup
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer cancel()
-		log.Infof("Bot is now running. Press CTRL-C to exit.")
-		sc := make(chan os.Signal, 1)
-		signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-		s := <-sc
-		log.Infof("got signal %v", s)
-		cb.dg.Close()
+		cb.watchServers(ctx)
 	}()
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer cancel()
-
-		for ctx.Err() == nil {
-			if err := cb.PollWebdis(ctx); err != nil {
-				log.Errorf("cb.PollWebdis failure: %v", err)
-			}
-		}
+		cb.cursors.run(ctx)
 	}()
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer cancel()
+	for _, route := range cb.routes {
+		route := route
+		bridge := cb.bridge(route)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cb.runOutbox(ctx, route, bridge)
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+
+			retry := newBackoff(time.Second, backoffMax)
+			for ctx.Err() == nil {
+				started := time.Now()
+
+				var err error
+				switch route.Delivery {
+				case DeliverySubscribe:
+					err = cb.Subscribe(ctx, route)
+				case DeliveryQueue:
+					err = cb.Queue(ctx, route)
+				default:
+					err = cb.PollWebdis(ctx, route)
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				if err == nil {
+					err = errors.Errorf("delivery ended")
+				}
+				cb.health.failed(route, err)
 
-		ticker := time.NewTicker(time.Hour * 3)
+				// a delivery that worked for a while starts over
+				if time.Since(started) > backoffMax {
+					retry.reset()
+				}
+				log.Errorf("[%v] %v delivery failure, retry in %v: %v", route, route.Delivery, retry.next, err)
+				retry.wait(ctx)
+			}
+		}()
 
-		for {
-			log.Infof("check delete message on %v", cb.channelId)
+		if route.Retention.Age <= 0 {
+			log.Infof("[%v] retention is disabled", route)
+			continue
+		}
 
-			var msgids []string
-			pivot := time.Now().Add(-(time.Hour * 24))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
 
-			for msg := range cb.getAllMessages(cb.channelId) {
-				if pivot.After(msg.Timestamp) {
-					log.Infof("delete message: %v %v", msg.Timestamp, msg.Content)
-					msgids = append(msgids, msg.ID)
-				}
-			}
+			ticker := time.NewTicker(route.Retention.Interval)
 
-			for len(msgids) > 0 {
-				var ids []string
-				if len(msgids) > 100 {
-					ids = msgids[0:100]
-					msgids = msgids[100:]
-				} else {
-					ids = msgids
-					msgids = nil
+			for {
+				if err := bridge.Retain(ctx, route); err != nil {
+					log.Errorf("[%v] %v.Retain failure: %v", route, bridge.Name(), err)
 				}
 
-				if err := cb.dg.ChannelMessagesBulkDelete(cb.channelId, ids); err != nil {
-					log.Errorf("dg.ChannelMessagesBulkDelete failure: %v", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
 				}
 			}
-
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-			}
-		}
-	}()
+		}()
+	}
 
 	wg.Wait()
+	log.Infof("Bot is shutting down")
 	return ctx.Err()
 }
 
-func (cb *ChatBot) getAllMessages(channelId string) <-chan *discordgo.Message {
-
-	ch := make(chan *discordgo.Message)
-	go func() {
-		defer close(ch)
-
-		var lastId string
+// sendTimeout bounds sending a message posted on a bridge to the game
+const sendTimeout = 30 * time.Second
 
-		for {
-			msgs, err := cb.dg.ChannelMessages(cb.channelId, 100, lastId, "", "")
-			if err != nil {
-				log.Errorf("dg.ChannelMessages failure: %v", err)
-				return
-			}
-
-			if len(msgs) == 0 {
-				return
-			}
+// receive sends a message posted on a bridge to the cluster of its routes
+func (cb *ChatBot) receive(msg *BridgeMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
 
-			for _, msg := range msgs {
-				ch <- msg
-			}
-			lastId = msgs[len(msgs)-1].ID
+	// the survivor linked with /link replaces the discord nickname
+	if msg.Bridge == DiscordBridge {
+		if identity := cb.identities.get(msg.UserId); identity != nil {
+			msg.Tribe, msg.Player = identity.Tribe, identity.Survivor
 		}
-
-	}()
-
-	return ch
-}
-
-func (cb *ChatBot) MessageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.ID == s.State.User.ID {
-		return
 	}
 
-	if m.ChannelID != cb.channelId {
-		return
-	}
+	content, ok := cb.moderator.filter(ToGame, "", msg.UserId, msg.Player, msg.Content)
 
-	//content := m.Content
-	var nick = m.Member.Nick
-	var tribe string
-	var player string
+	// several routes of one channel may share a cluster
+	sent := map[string]bool{}
+	for _, route := range cb.routes {
+		if !strings.EqualFold(route.Bridge, msg.Bridge) || route.ChannelId != msg.ChannelId {
+			continue
+		}
 
-	pos1 := strings.Index(nick, "[")
-	pos2 := strings.Index(nick, "]")
+		if sent[route.Cluster] {
+			continue
+		}
+		sent[route.Cluster] = true
 
-	if pos1 != -1 && pos2 != -1 && pos1 < pos2 {
-		tribe = nick[pos1+1 : pos2]
-		player = nick[pos2+1:]
-	} else {
-		tribe = ""
-		player = nick
-	}
+		cb.recordBridge(route, msg)
+		if !ok {
+			continue
+		}
 
-	cb.SendWebdis("ChatBot", "Discord", tribe, player, m.Content)
+		if err := cb.sendParts(ctx, route, msg.Bridge, msg.Tribe, msg.Player, content); err != nil {
+			log.Errorf("[%v] cb.SendWebdis failure: %v", route, err)
+		}
+	}
 }
 
-func (cb *ChatBot) ForwardDiscord(msg *RedisMessage) {
-	cb.dg.ChannelMessageSend(
-		cb.channelId,
-		fmt.Sprintf(cb.format, msg.ServerName, msg.TribeName, msg.SurvivorName, msg.Message),
-	)
+// sendParts sends content split to the in-game chat length limit
+func (cb *ChatBot) sendParts(ctx context.Context, route *Route, server, tribe, player, content string) (err error) {
+	for _, part := range splitMessage(content, gameMessageMax) {
+		if err := cb.SendWebdis(ctx, route, "ChatBot", server, tribe, player, part); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (cb *ChatBot) LPush(msg any) (err error) {
+func (cb *ChatBot) LPush(ctx context.Context, route *Route, msg any) (err error) {
 	b, err := json.Marshal(msg)
 	if err != nil {
 		return errors.Wrap(err, "json.Marshal")
 	}
 
-	j := string(b)
-	data := fmt.Sprintf("LPUSH/%v/%v", cb.clusterId, url.QueryEscape(j))
-	req, err := http.NewRequest("POST", cb.webdisAddr, strings.NewReader(data))
-	if err != nil {
-		return errors.Wrap(err, "http.NewRequest")
-	}
-
-	resp, err := cb.client.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "client.Do")
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return errors.Wrap(err, "ioutil.ReadAll")
+	if err := cb.transport.LPush(ctx, route.Cluster, string(b)); err != nil {
+		return errors.Wrap(err, "transport.LPush")
 	}
-
-	log.Infof("LPUSH => %v %v", resp.Status, string(body))
 	return nil
 }
 
-func (cb *ChatBot) LTrim(start, end int) (err error) {
-	data := fmt.Sprintf("LTRIM/%v/%v/%v", cb.clusterId, start, end)
-	req, err := http.NewRequest("POST", cb.webdisAddr, strings.NewReader(data))
+func (cb *ChatBot) Publish(ctx context.Context, route *Route, msg any) (err error) {
+	b, err := json.Marshal(msg)
 	if err != nil {
-		return errors.Wrap(err, "http.NewRequest")
+		return errors.Wrap(err, "json.Marshal")
 	}
 
-	resp, err := cb.client.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "client.Do")
+	if err := cb.transport.Publish(ctx, route.ChatChannel, string(b)); err != nil {
+		return errors.Wrap(err, "transport.Publish")
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return errors.Wrap(err, "ioutil.ReadAll")
+func (cb *ChatBot) LTrim(ctx context.Context, route *Route, start, end int) (err error) {
+	if err := cb.transport.LTrim(ctx, route.Cluster, start, end); err != nil {
+		return errors.Wrap(err, "transport.LTrim")
 	}
-
-	log.Infof("LTRIM => %v %v", resp.Status, string(body))
 	return nil
 }
 
@@ -259,53 +337,31 @@ type RedisMessage struct {
 	SurvivorName string    `json:"SurvivorName"`
 	TribeName    string    `json:"TribeName"`
 	Message      string    `json:"Message"`
-}
 
-func (cb *ChatBot) LRange(start, end int) (items []*RedisMessage, err error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%v/LRANGE/%v/%v/%v", cb.webdisAddr, cb.clusterId, start, end), nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "http.NewRequest")
-	}
+	hash string
+}
 
-	resp, err := cb.client.Do(req)
+func (cb *ChatBot) LRange(ctx context.Context, route *Route, start, end int) (items []*RedisMessage, err error) {
+	arr, err := cb.transport.LRange(ctx, route.Cluster, start, end)
 	if err != nil {
-		return nil, errors.Wrap(err, "client.Do")
-	}
-	defer resp.Body.Close()
-
-	// ERROR  {"LRANGE":[false,"ERR wrong number of arguments for 'lrange' command"]}
-	// NORMAL {"LRANGE":["....","....."]}
-	var result = map[string][]any{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, errors.Wrap(err, "json.Decode")
-	}
-
-	if _, has := result["LRANGE"]; !has {
-		return nil, errors.Errorf("invalid result: LRANGE not exist")
-	}
-
-	arr := result["LRANGE"]
-	if len(arr) == 2 {
-		if b, ok := arr[0].(bool); ok && b == false {
-			return nil, errors.Errorf("LRANGE failure: %v", arr[1])
-		}
+		return nil, errors.Wrap(err, "transport.LRange")
 	}
 
 	for _, item := range arr {
-		r := strings.NewReader(item.(string))
+		r := strings.NewReader(item)
 		var msg *RedisMessage
 		if err := json.NewDecoder(r).Decode(&msg); err != nil {
 			log.Errorf("json.Decode failure: %v", err)
 			continue
 		}
+		msg.hash = hashMessage(item)
 		items = append(items, msg)
 	}
 
-	//log.Infof("LRANGE => %v", resp.Status)
 	return items, nil
 }
 
-func (cb *ChatBot) SendWebdis(session, server, tribe, player, content string) (err error) {
+func (cb *ChatBot) SendWebdis(ctx context.Context, route *Route, session, server, tribe, player, content string) (err error) {
 	now := time.Now().UTC()
 	epoch := float64(now.UnixMicro()) / 1000000
 	year := fmt.Sprintf("%04d", now.Year())
@@ -327,46 +383,118 @@ func (cb *ChatBot) SendWebdis(session, server, tribe, player, content string) (e
 		Message:      content,
 	}
 
-	if err := cb.LPush(msg); err != nil {
+	if err := cb.LPush(ctx, route, msg); err != nil {
 		return errors.Wrap(err, "cb.LPush")
 	}
 
-	if err := cb.LTrim(0, 9); err != nil {
-		return errors.Wrap(err, "cb.LPush")
+	if err := cb.LTrim(ctx, route, 0, 9); err != nil {
+		return errors.Wrap(err, "cb.LTrim")
+	}
+
+	if route.Delivery == DeliverySubscribe {
+		if err := cb.Publish(ctx, route, msg); err != nil {
+			return errors.Wrap(err, "cb.Publish")
+		}
+	}
+
+	// the queue routes of the cluster see the message as they would in the
+	// list, the route itself drops it
+	queued := map[string]bool{}
+	for _, r := range cb.routes {
+		if r.Delivery != DeliveryQueue || r.Cluster != route.Cluster || queued[r.Queue] {
+			continue
+		}
+		queued[r.Queue] = true
+
+		if err := cb.pushQueue(ctx, r, msg); err != nil {
+			return errors.Wrap(err, "cb.pushQueue")
+		}
 	}
 
 	return nil
 }
 
-func (cb *ChatBot) PollWebdis(ctx context.Context) (err error) {
-	var last float64
+func (cb *ChatBot) pushQueue(ctx context.Context, route *Route, msg any) (err error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	if err := cb.transport.LPush(ctx, route.Queue, string(b)); err != nil {
+		return errors.Wrap(err, "transport.LPush")
+	}
+	return nil
+}
 
-	for ctx.Err() == nil {
-		time.Sleep(500 * time.Millisecond)
+func (cb *ChatBot) PollWebdis(ctx context.Context, route *Route) (err error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
 
-		msgs, err := cb.LRange(0, 19)
+		msgs, err := cb.LRange(ctx, route, 0, 19)
 		if err != nil {
 			return errors.Wrap(err, "cb.LRange")
 		}
+		cb.health.polled(route)
 
-		if last == 0 && len(msgs) > 0 {
-			last = msgs[0].Epoch
-			continue
-		}
+		cb.forwardAfterCursor(route, msgs)
+	}
+}
 
-		for i := len(msgs) - 1; i >= 0; i-- {
-			msg := msgs[i]
-			if last >= msg.Epoch {
-				continue
-			}
+// forwardAfterCursor forwards the messages of the list newer than the route
+// cursor. Without a cursor it starts from the newest message; with one
+// restored from the state file at most catchupMax messages are caught up.
+func (cb *ChatBot) forwardAfterCursor(route *Route, msgs []*RedisMessage) {
+	key := route.String()
 
-			if msg.ServerName == "Discord" {
-				continue
-			}
+	cursor := cb.cursors.get(key)
+	if cursor == nil {
+		if len(msgs) > 0 {
+			cb.setCursor(route, msgs[0])
+		}
+		return
+	}
+
+	news := after(msgs, cursor)
 
-			cb.ForwardDiscord(msgs[i])
-			last = msg.Epoch
+	if cb.cursors.restored(key) {
+		if len(news) > cb.catchupMax {
+			log.Warnf("[%v] skip %v messages sent while the bot was down", route, len(news)-cb.catchupMax)
+			news = news[len(news)-cb.catchupMax:]
 		}
 	}
-	return nil
+
+	for _, msg := range news {
+		cb.setCursor(route, msg)
+		cb.recordGame(route, msg)
+		cb.relay(route, msg)
+	}
+}
+
+// relay forwards an in-game message on the route when it is accepted and
+// passes moderation
+func (cb *ChatBot) relay(route *Route, msg *RedisMessage) {
+	if !route.accept(msg) {
+		return
+	}
+
+	content, ok := cb.moderator.filter(ToBridge, msg.hash, "", msg.SurvivorName, msg.Message)
+	if !ok {
+		return
+	}
+
+	if content != msg.Message {
+		filtered := *msg
+		filtered.Message = content
+		msg = &filtered
+	}
+
+	cb.Forward(route, msg)
+}
+
+func (cb *ChatBot) setCursor(route *Route, msg *RedisMessage) {
+	cb.cursors.set(route.String(), &Cursor{Epoch: msg.Epoch, Hash: msg.hash})
 }
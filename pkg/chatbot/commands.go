@@ -0,0 +1,432 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/jeehoon/arktools/pkg/rcon"
+	"github.com/pkg/errors"
+)
+
+// commandTimeout bounds one RCON round trip of a slash command
+const commandTimeout = 10 * time.Second
+
+type command struct {
+	def *discordgo.ApplicationCommand
+	// rcon returns the console commands to run on each server
+	rcon func(opts map[string]string) []string
+	// local runs the command in the bot instead of the servers
+	local func(cb *ChatBot, user *discordgo.User, opts map[string]string) (string, error)
+	// public commands may be run by every member, not only the admins
+	public bool
+}
+
+var serverOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionString,
+	Name:        "server",
+	Description: "server name (all servers when omitted)",
+}
+
+// requiredServerOption is the server of the commands that disrupt players,
+// a mistyped one never runs on the whole cluster
+var requiredServerOption = &discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionString,
+	Name:        "server",
+	Description: "server name",
+	Required:    true,
+}
+
+var commands = []*command{
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "players",
+			Description: "List online players",
+			Options:     []*discordgo.ApplicationCommandOption{serverOption},
+		},
+		rcon: func(opts map[string]string) []string {
+			return []string{"ListPlayers"}
+		},
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "broadcast",
+			Description: "Broadcast a message to players",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "message",
+					Description: "message",
+					Required:    true,
+				},
+				serverOption,
+			},
+		},
+		rcon: func(opts map[string]string) []string {
+			return []string{"Broadcast " + opts["message"]}
+		},
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "save",
+			Description: "Save the world",
+			Options:     []*discordgo.ApplicationCommandOption{serverOption},
+		},
+		rcon: func(opts map[string]string) []string {
+			return []string{"SaveWorld"}
+		},
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "kick",
+			Description: "Kick a player",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "steamid",
+					Description: "steam id of the player",
+					Required:    true,
+				},
+				requiredServerOption,
+			},
+		},
+		rcon: func(opts map[string]string) []string {
+			return []string{"KickPlayer " + opts["steamid"]}
+		},
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "chatlog",
+			Description: "Search the chat archive",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "player",
+					Description: "survivor name",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "contains",
+					Description: "text of the message",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "since",
+					Description: "how far back to search, e.g. 24h (default 24h)",
+				},
+			},
+		},
+		local: (*ChatBot).chatlog,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "link",
+			Description: "Link your discord account to your survivor, your chat shows in game as it",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "survivor",
+					Description: "survivor name",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "tribe",
+					Description: "tribe name",
+				},
+			},
+		},
+		local:  (*ChatBot).link,
+		public: true,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "unlink",
+			Description: "Unlink your discord account from your survivor",
+		},
+		local:  (*ChatBot).unlink,
+		public: true,
+	},
+	{
+		def: &discordgo.ApplicationCommand{
+			Name:        "restart",
+			Description: "Save the world and exit the server, to be restarted by its supervisor",
+			Options:     []*discordgo.ApplicationCommandOption{requiredServerOption},
+		},
+		rcon: func(opts map[string]string) []string {
+			return []string{"SaveWorld", "DoExit"}
+		},
+	},
+}
+
+// SetCommands enables the slash commands. Only members with one of the
+// adminRoles may run them. Every invocation is logged and, when auditLog
+// is set, appended to it as a json line.
+func (cb *ChatBot) SetCommands(guildId string, servers []*rcon.Server, adminRoles []string, auditLog string) {
+	cb.guildId = guildId
+	cb.servers = servers
+	cb.adminRoles = adminRoles
+	cb.auditLog = auditLog
+}
+
+func (cb *ChatBot) registerCommands(s *discordgo.Session) (err error) {
+	defs := []*discordgo.ApplicationCommand{}
+	for _, cmd := range commands {
+		if cmd.local == nil && len(cb.servers) == 0 {
+			continue
+		}
+		if cmd.def.Name == "chatlog" && cb.archive == nil {
+			continue
+		}
+		if (cmd.def.Name == "link" || cmd.def.Name == "unlink") && cb.identities == nil {
+			continue
+		}
+		defs = append(defs, cmd.def)
+	}
+
+	// with no definitions too, commands of an earlier config are removed
+	if _, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, cb.guildId, defs); err != nil {
+		return errors.Wrap(err, "dg.ApplicationCommandBulkOverwrite")
+	}
+
+	return nil
+}
+
+func (cb *ChatBot) InteractionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	data := i.ApplicationCommandData()
+
+	var cmd *command
+	for _, c := range commands {
+		if c.def.Name == data.Name {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		return
+	}
+
+	opts := map[string]string{}
+	for _, opt := range data.Options {
+		opts[opt.Name] = fmt.Sprintf("%v", opt.Value)
+	}
+
+	entry := &auditEntry{
+		Time:    time.Now(),
+		Command: data.Name,
+		Options: opts,
+	}
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user != nil {
+		entry.UserId = user.ID
+		entry.User = user.String()
+	}
+	defer cb.audit(entry)
+
+	if !cmd.public && !cb.isAdmin(i.Member) || user == nil {
+		entry.Result = "denied"
+		cb.replyEphemeral(s, i.Interaction, "You are not allowed to run this command.")
+		return
+	}
+
+	var servers []*rcon.Server
+	if cmd.local == nil {
+		// discord may still show a definition registered before the server
+		// was required
+		if opts["server"] == "" && hasOption(cmd.def, requiredServerOption) {
+			entry.Result = "no server"
+			cb.replyEphemeral(s, i.Interaction, "The server option is required.")
+			return
+		}
+
+		var err error
+		servers, err = cb.findServers(opts["server"])
+		if err != nil {
+			entry.Result = err.Error()
+			cb.replyEphemeral(s, i.Interaction, err.Error())
+			return
+		}
+	}
+
+	// RCON may take longer than the interaction deadline of 3 seconds
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		entry.Result = err.Error()
+		log.Errorf("dg.InteractionRespond failure: %v", err)
+		return
+	}
+
+	if cmd.local != nil {
+		content, err := cmd.local(cb, user, opts)
+		if err != nil {
+			content = fmt.Sprintf("error: %v", err)
+		}
+		entry.Result = content
+		cb.editResponse(s, i.Interaction, content)
+		return
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, len(servers))
+
+	for idx, server := range servers {
+		idx, server := idx, server
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			out, err := cb.runRcon(server, cmd.rcon(opts))
+			if err != nil {
+				out = fmt.Sprintf("error: %v", err)
+			}
+			results[idx] = fmt.Sprintf("**%v**\n```\n%v\n```", server.Name, strings.TrimSpace(out))
+		}()
+	}
+	wg.Wait()
+
+	content := strings.Join(results, "\n")
+	entry.Result = content
+	cb.editResponse(s, i.Interaction, content)
+}
+
+func (cb *ChatBot) editResponse(s *discordgo.Session, i *discordgo.Interaction, content string) {
+	if len(content) > 2000 {
+		content = truncate(content, 1990) + "\n...```"
+	}
+
+	if _, err := s.InteractionResponseEdit(i, &discordgo.WebhookEdit{Content: &content}); err != nil {
+		log.Errorf("dg.InteractionResponseEdit failure: %v", err)
+	}
+}
+
+func (cb *ChatBot) isAdmin(member *discordgo.Member) bool {
+	if member == nil {
+		return false
+	}
+
+	for _, role := range member.Roles {
+		for _, admin := range cb.adminRoles {
+			if role == admin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasOption(def *discordgo.ApplicationCommand, option *discordgo.ApplicationCommandOption) bool {
+	for _, opt := range def.Options {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+func (cb *ChatBot) findServers(name string) (servers []*rcon.Server, err error) {
+	if name == "" {
+		return cb.servers, nil
+	}
+
+	for _, server := range cb.servers {
+		if strings.EqualFold(server.Name, name) {
+			return []*rcon.Server{server}, nil
+		}
+	}
+
+	var names []string
+	for _, server := range cb.servers {
+		names = append(names, server.Name)
+	}
+	return nil, errors.Errorf("unknown server %q (servers: %v)", name, strings.Join(names, ", "))
+}
+
+func (cb *ChatBot) runRcon(server *rcon.Server, commands []string) (out string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	return server.Run(ctx, commands...)
+}
+
+func (cb *ChatBot) listPlayers(server *rcon.Server) (players map[string]string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	return server.ListPlayers(ctx)
+}
+
+func (cb *ChatBot) replyEphemeral(s *discordgo.Session, i *discordgo.Interaction, content string) {
+	if err := s.InteractionRespond(i, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		log.Errorf("dg.InteractionRespond failure: %v", err)
+	}
+}
+
+type auditEntry struct {
+	Time    time.Time         `json:"time"`
+	UserId  string            `json:"user_id"`
+	User    string            `json:"user"`
+	Command string            `json:"command"`
+	Options map[string]string `json:"options,omitempty"`
+	Result  string            `json:"result"`
+}
+
+func (cb *ChatBot) audit(entry *auditEntry) {
+	log.Infof("AUDIT %v(%v) /%v %v => %q", entry.User, entry.UserId, entry.Command, entry.Options, entry.Result)
+
+	if cb.auditLog == "" {
+		return
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("json.Marshal failure: %v", err)
+		return
+	}
+
+	cb.auditMu.Lock()
+	defer cb.auditMu.Unlock()
+
+	f, err := os.OpenFile(cb.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Errorf("os.OpenFile(%v) failure: %v", cb.auditLog, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		log.Errorf("audit log write failure: %v", err)
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a utf-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,196 @@
+package chatbot
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Cursor is the last in-game message forwarded on a route. Epochs of the
+// game servers are not unique, so the hash of the raw message breaks ties.
+type Cursor struct {
+	Epoch float64 `json:"epoch"`
+	Hash  string  `json:"hash"`
+}
+
+func hashMessage(raw string) string {
+	sum := sha1.Sum([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// cursorFlushInterval is how often the cursors set since the last flush are
+// written to the state file
+const cursorFlushInterval = 5 * time.Second
+
+// cursorStore keeps the cursor of each route, persisted to path by flush
+type cursorStore struct {
+	path string
+
+	mu      sync.Mutex
+	cursors map[string]*Cursor
+	loaded  map[string]bool
+	// dirty is whether a cursor was set since the last flush
+	dirty bool
+}
+
+func newCursorStore(path string) *cursorStore {
+	return &cursorStore{
+		path:    path,
+		cursors: map[string]*Cursor{},
+		loaded:  map[string]bool{},
+	}
+}
+
+func (store *cursorStore) load() (err error) {
+	if store.path == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "ioutil.ReadFile(%v)", store.path)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := json.Unmarshal(b, &store.cursors); err != nil {
+		return errors.Wrapf(err, "json.Unmarshal(%v)", store.path)
+	}
+
+	for key := range store.cursors {
+		store.loaded[key] = true
+	}
+	return nil
+}
+
+// restored reports, once, whether the cursor of key was loaded from the
+// state file rather than set by this process
+func (store *cursorStore) restored(key string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if !store.loaded[key] {
+		return false
+	}
+	delete(store.loaded, key)
+	return true
+}
+
+func (store *cursorStore) get(key string) *Cursor {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.cursors[key]
+}
+
+func (store *cursorStore) set(key string, cursor *Cursor) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.cursors[key] = cursor
+	store.dirty = true
+}
+
+// flush writes the cursors to path when one was set since the last flush
+func (store *cursorStore) flush() (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.path == "" || !store.dirty {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(store.cursors, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	if err := writeFile(store.path, b); err != nil {
+		return err
+	}
+	store.dirty = false
+	return nil
+}
+
+// run flushes the cursors every cursorFlushInterval until ctx is done, the
+// last flush is left to the caller once nothing sets them anymore
+func (store *cursorStore) run(ctx context.Context) {
+	ticker := time.NewTicker(cursorFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := store.flush(); err != nil {
+			log.Errorf("cursors.flush failure: %v", err)
+		}
+	}
+}
+
+// writeFile writes and renames, a crash never leaves a truncated file
+func writeFile(path string, b []byte) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "ioutil.TempFile")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "Write")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "os.Rename(%v)", path)
+	}
+
+	return nil
+}
+
+// after returns the messages newer than cursor, oldest first. msgs is the
+// cluster chat list, newest first.
+func after(msgs []*RedisMessage, cursor *Cursor) (news []*RedisMessage) {
+	pos := -1
+	for i, msg := range msgs {
+		if msg.hash == cursor.Hash {
+			pos = i
+			break
+		}
+	}
+
+	if pos == -1 {
+		// the cursor was trimmed out of the list
+		for i := len(msgs) - 1; i >= 0; i-- {
+			if msgs[i].Epoch > cursor.Epoch {
+				news = append(news, msgs[i])
+			}
+		}
+		return news
+	}
+
+	for i := pos - 1; i >= 0; i-- {
+		news = append(news, msgs[i])
+	}
+	return news
+}
@@ -0,0 +1,144 @@
+package chatbot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorStoreFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	store := newCursorStore(path)
+	assert.Nil(t, store.load())
+
+	// nothing set, nothing written
+	assert.Nil(t, store.flush())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// set only keeps the cursor in memory
+	store.set("route", &Cursor{Epoch: 1, Hash: "a"})
+	store.set("route", &Cursor{Epoch: 2, Hash: "b"})
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, store.flush())
+
+	restored := newCursorStore(path)
+	assert.Nil(t, restored.load())
+	assert.Equal(t, &Cursor{Epoch: 2, Hash: "b"}, restored.get("route"))
+	assert.True(t, restored.restored("route"))
+	assert.False(t, restored.restored("route"))
+
+	// a flush without changes leaves the file alone
+	assert.Nil(t, os.Remove(path))
+	assert.Nil(t, store.flush())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// chatList returns the cluster chat list of messages with epochs, given
+// oldest first, newest first as LRANGE returns it
+func chatList(epochs ...float64) (msgs []*RedisMessage) {
+	for i, epoch := range epochs {
+		msg := &RedisMessage{Epoch: epoch, SurvivorName: "Bob", Message: fmt.Sprintf("m%v", i)}
+		msg.hash = hashMessage(fmt.Sprintf("%v|%v", epoch, msg.Message))
+		msgs = append([]*RedisMessage{msg}, msgs...)
+	}
+	return msgs
+}
+
+func messages(msgs []*RedisMessage) (contents []string) {
+	for _, msg := range msgs {
+		contents = append(contents, msg.Message)
+	}
+	return contents
+}
+
+func TestAfter(t *testing.T) {
+	// m0 .. m4, m1 and m2 share an epoch
+	msgs := chatList(10, 20, 20, 30, 40)
+	at := func(i int) *Cursor {
+		msg := msgs[len(msgs)-1-i]
+		return &Cursor{Epoch: msg.Epoch, Hash: msg.hash}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		cursor *Cursor
+		news   []string
+	}{
+		{"newest", at(4), nil},
+		{"in the list", at(2), []string{"m3", "m4"}},
+		{"equal epoch after the cursor", at(1), []string{"m2", "m3", "m4"}},
+		{"oldest", at(0), []string{"m1", "m2", "m3", "m4"}},
+		{"trimmed, by epoch", &Cursor{Epoch: 20, Hash: "gone"}, []string{"m3", "m4"}},
+		{"older than the window", &Cursor{Epoch: 5, Hash: "gone"}, []string{"m0", "m1", "m2", "m3", "m4"}},
+		{"newer than the list", &Cursor{Epoch: 50, Hash: "gone"}, nil},
+	} {
+		assert.Equal(t, tc.news, messages(after(msgs, tc.cursor)), tc.name)
+	}
+
+	assert.Nil(t, after(nil, at(0)))
+}
+
+func newCursorBot(t *testing.T, path string, catchupMax int) (cb *ChatBot, route *Route) {
+	route = &Route{Cluster: "cluster", ChannelId: "1"}
+	cb = New(nil, []*Route{route})
+	cb.SetState(path, catchupMax)
+	if err := cb.cursors.load(); err != nil {
+		t.Fatal(err)
+	}
+	return cb, route
+}
+
+func TestForwardAfterCursor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// no state file, the bot starts from the newest message
+	cb, route := newCursorBot(t, path, 2)
+	msgs := chatList(10, 20, 30)
+	cb.forwardAfterCursor(route, msgs)
+	assert.Nil(t, messages(cb.outboxes[route].take()))
+
+	msgs = chatList(10, 20, 30, 40)
+	cb.forwardAfterCursor(route, msgs)
+	assert.Equal(t, []string{"m3"}, messages(cb.outboxes[route].take()))
+	assert.Nil(t, cb.cursors.flush())
+
+	// five messages while the bot was down, the newest two are caught up
+	cb, route = newCursorBot(t, path, 2)
+	msgs = chatList(10, 20, 30, 40, 50, 60, 70, 70, 80)
+	cb.forwardAfterCursor(route, msgs)
+	assert.Equal(t, []string{"m7", "m8"}, messages(cb.outboxes[route].take()))
+	assert.Equal(t, msgs[0].hash, cb.cursors.get(route.String()).Hash)
+
+	// the cap only applies once after the restart
+	msgs = chatList(10, 20, 30, 40, 50, 60, 70, 70, 80, 90, 100, 110)
+	cb.forwardAfterCursor(route, msgs)
+	assert.Equal(t, []string{"m9", "m10", "m11"}, messages(cb.outboxes[route].take()))
+
+	// fewer than the cap are all caught up
+	assert.Nil(t, cb.cursors.flush())
+	cb, route = newCursorBot(t, path, 2)
+	msgs = chatList(10, 20, 30, 40, 50, 60, 70, 70, 80, 90, 100, 110, 120)
+	cb.forwardAfterCursor(route, msgs)
+	assert.Equal(t, []string{"m12"}, messages(cb.outboxes[route].take()))
+}
+
+func TestCursorStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"cluster=>1": {"epoch": `), 0600))
+
+	store := newCursorStore(path)
+	assert.NotNil(t, store.load())
+
+	// a missing file is a first start
+	store = newCursorStore(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Nil(t, store.load())
+	assert.Nil(t, store.get("cluster=>1"))
+}
@@ -0,0 +1,358 @@
+package chatbot
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// DiscordBridge is the name of the discord bridge
+const DiscordBridge = "Discord"
+
+const (
+	// discordReconnectAfter is how long a session may stay disconnected
+	// before the watchdog reopens it, and the least time between two reopens.
+	// The reconnect loop of discordgo is off, it waits up to 10 minutes
+	// between attempts and never stops.
+	discordReconnectAfter = 15 * time.Second
+	// discordMessageMax is the content length limit of a discord message
+	discordMessageMax = 2000
+	// embedsMax is the number of embeds of a discord message
+	embedsMax = 10
+)
+
+// Discord is the Bridge of a discord bot
+type Discord struct {
+	token string
+
+	dg       *discordgo.Session
+	webhooks webhooks
+	routes   []*Route
+	recv     func(msg *BridgeMessage)
+
+	// handlers and onOpen hook the slash commands of the chatbot
+	handlers []any
+	onOpen   []func(s *discordgo.Session) error
+
+	statusMu sync.Mutex
+	presence string
+	topics   map[string]*topic
+
+	connMu    sync.Mutex
+	connected bool
+	changed   time.Time
+	cancel    context.CancelFunc
+}
+
+// topic is the last player count shown in a channel topic
+type topic struct {
+	text    string
+	updated time.Time
+}
+
+func NewDiscord(apiToken string) *Discord {
+	return &Discord{token: apiToken}
+}
+
+func (d *Discord) Name() string {
+	return DiscordBridge
+}
+
+func (d *Discord) Open(ctx context.Context, routes []*Route, recv func(msg *BridgeMessage)) (err error) {
+	d.routes = routes
+	d.recv = recv
+
+	d.dg, err = discordgo.New("Bot " + d.token)
+	if err != nil {
+		return errors.Wrap(err, "discordgo.New")
+	}
+	d.dg.AddHandler(d.MessageHandler)
+	d.dg.AddHandler(func(s *discordgo.Session, _ *discordgo.Connect) { d.setConnected(true) })
+	d.dg.AddHandler(func(s *discordgo.Session, _ *discordgo.Resumed) { d.setConnected(true) })
+	d.dg.AddHandler(func(s *discordgo.Session, _ *discordgo.Disconnect) { d.setConnected(false) })
+	for _, handler := range d.handlers {
+		d.dg.AddHandler(handler)
+	}
+	d.dg.Identify.Intents = discordgo.IntentGuildMessages
+	// the watchdog is the only one to reopen the session
+	d.dg.ShouldReconnectOnError = false
+
+	d.setConnected(false)
+	if err := d.open(); err != nil {
+		return err
+	}
+
+	ctx, d.cancel = context.WithCancel(ctx)
+	go d.watchdog(ctx)
+
+	return nil
+}
+
+// open opens the session and runs the onOpen hooks, Open and the watchdog
+// both go through it
+func (d *Discord) open() (err error) {
+	if err := d.dg.Open(); err != nil {
+		return errors.Wrap(err, "dg.Open")
+	}
+
+	for _, fn := range d.onOpen {
+		if err := fn(d.dg); err != nil {
+			d.dg.Close()
+			return err
+		}
+	}
+
+	// known webhooks are told apart from other users by the handler and retention
+	for _, route := range d.routes {
+		if route.Webhook {
+			if _, err := d.webhooks.get(d.dg, route.ChannelId); err != nil {
+				log.Warnf("[%v] webhooks.get failure: %v", route, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Discord) Close() (err error) {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	if d.dg == nil {
+		return nil
+	}
+	return d.dg.Close()
+}
+
+func (d *Discord) setConnected(connected bool) {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+
+	if d.connected != connected || d.changed.IsZero() {
+		d.connected = connected
+		d.changed = time.Now()
+	}
+}
+
+func (d *Discord) Connected() (ok bool, since time.Time) {
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	return d.connected, d.changed
+}
+
+// watchdog reopens the session when it stays disconnected longer than
+// discordReconnectAfter, until ctx is done
+func (d *Discord) watchdog(ctx context.Context) {
+	ticker := time.NewTicker(discordReconnectAfter / 4)
+	defer ticker.Stop()
+
+	var reopened time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		connected, since := d.Connected()
+		if connected || time.Since(since) < discordReconnectAfter || time.Since(reopened) < discordReconnectAfter {
+			continue
+		}
+		reopened = time.Now()
+
+		log.Warnf("discord disconnected since %v, reopen the session", since.Format(time.RFC3339))
+		d.dg.Close()
+		if err := d.open(); err != nil {
+			log.Errorf("d.open failure: %v", err)
+		}
+	}
+}
+
+func (d *Discord) MessageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.Author == nil || m.Author.ID == s.State.User.ID {
+		return
+	}
+
+	if m.WebhookID != "" && d.webhooks.owns(m.WebhookID) {
+		return
+	}
+
+	found := false
+	for _, route := range d.routes {
+		if route.ChannelId == m.ChannelID {
+			found = true
+		}
+	}
+
+	if !found {
+		return
+	}
+
+	tribe, player := parseNick(displayName(s, m))
+	if player == "" {
+		player = m.Author.Username
+	}
+
+	content := gameContent(s, m.Message)
+	if content == "" {
+		return
+	}
+
+	d.recv(&BridgeMessage{
+		Bridge:    DiscordBridge,
+		ChannelId: m.ChannelID,
+		UserId:    m.Author.ID,
+		Tribe:     tribe,
+		Player:    player,
+		Content:   content,
+	})
+}
+
+func (d *Discord) Send(ctx context.Context, route *Route, msgs []*RedisMessage) (n int, err error) {
+	chunk := chunkMessages(route, msgs)
+
+	if route.Webhook {
+		err = retry(ctx, func(opts ...discordgo.RequestOption) error {
+			return d.sendWebhook(route, chunk, opts...)
+		})
+		if err == nil || ctx.Err() != nil {
+			return len(chunk), err
+		}
+		log.Warnf("[%v] sendWebhook failure, fall back to the bot: %v", route, err)
+	}
+
+	err = retry(ctx, func(opts ...discordgo.RequestOption) error {
+		return d.sendBot(route, chunk, opts...)
+	})
+	return len(chunk), err
+}
+
+func (d *Discord) Retain(ctx context.Context, route *Route) (err error) {
+	d.applyRetention(route)
+	return nil
+}
+
+// chunkMessages returns the leading messages sent as one discord message
+func chunkMessages(route *Route, msgs []*RedisMessage) (chunk []*RedisMessage) {
+	length := 0
+	for _, msg := range msgs {
+		if len(chunk) > 0 {
+			// a webhook message has a single author
+			if route.Webhook && webhookUsername(msg) != webhookUsername(chunk[0]) {
+				break
+			}
+
+			if route.Style == StyleEmbed && len(chunk) == embedsMax {
+				break
+			}
+
+			if route.Style != StyleEmbed && length+1+len(renderText(route, msg)) > discordMessageMax {
+				break
+			}
+		}
+
+		if route.Style != StyleEmbed {
+			length += 1 + len(renderText(route, msg))
+		}
+		chunk = append(chunk, msg)
+	}
+	return chunk
+}
+
+// retry calls send until it succeeds, waiting as told by a rate limit or
+// backing off on server and network errors
+func retry(ctx context.Context, send func(opts ...discordgo.RequestOption) error) (err error) {
+	backoff := time.Second
+
+	for attempt := 1; ; attempt++ {
+		// the rate limit is waited for here, not inside discordgo
+		err = send(discordgo.WithRetryOnRatelimit(false))
+		if err == nil {
+			return nil
+		}
+
+		wait := backoff
+		backoff *= 2
+
+		var rateLimit *discordgo.RateLimitError
+		var restErr *discordgo.RESTError
+		switch {
+		case errors.As(err, &rateLimit):
+			wait = rateLimit.RetryAfter
+		case errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode < http.StatusInternalServerError:
+			// the request itself is wrong, retrying does not help
+			return err
+		}
+
+		if attempt == sendAttempts {
+			return errors.Wrapf(err, "%v attempts", attempt)
+		}
+
+		log.Debugf("discord send attempt %v failure, retry in %v: %v", attempt, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// topicInterval is the least time between channel topic updates, discord
+// allows two per 10 minutes
+const topicInterval = 6 * time.Minute
+
+func (d *Discord) Notify(ctx context.Context, route *Route, text string) (err error) {
+	return retry(ctx, func(opts ...discordgo.RequestOption) error {
+		_, err := d.dg.ChannelMessageSendComplex(route.ChannelId, &discordgo.MessageSend{
+			Content:         discordContent(text, false),
+			AllowedMentions: noMentions,
+		}, opts...)
+		return err
+	})
+}
+
+// SetStatus shows status in the channel topic or the presence of the bot
+func (d *Discord) SetStatus(ctx context.Context, route *Route, kind, status string) (err error) {
+	d.statusMu.Lock()
+	defer d.statusMu.Unlock()
+
+	if kind == PlayerCountPresence {
+		if d.presence == status {
+			return nil
+		}
+		if err := d.dg.UpdateWatchStatus(0, status); err != nil {
+			return errors.Wrap(err, "dg.UpdateWatchStatus")
+		}
+		d.presence = status
+		return nil
+	}
+
+	if d.topics == nil {
+		d.topics = map[string]*topic{}
+	}
+	t := d.topics[route.ChannelId]
+	if t == nil {
+		t = &topic{}
+		d.topics[route.ChannelId] = t
+	}
+
+	if t.text == status || time.Since(t.updated) < topicInterval {
+		return nil
+	}
+
+	// ChannelEdit would also reset the channel position
+	endpoint := discordgo.EndpointChannel(route.ChannelId)
+	if _, err := d.dg.RequestWithBucketID("PATCH", endpoint, map[string]string{"topic": status}, endpoint); err != nil {
+		return errors.Wrap(err, "PATCH channel topic")
+	}
+
+	t.text = status
+	t.updated = time.Now()
+	return nil
+}
@@ -0,0 +1,280 @@
+package chatbot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/jeehoon/arktools/pkg/rcon"
+)
+
+// player count displays of Events
+const (
+	// PlayerCountTopic shows the player count in the channel topic
+	PlayerCountTopic = "topic"
+	// PlayerCountPresence shows the player count in the presence of the bot
+	PlayerCountPresence = "presence"
+)
+
+// Events posts the player and tribe events of the game servers on a route
+type Events struct {
+	// Servers are the names of the watched servers, the server of the route
+	// or all servers by default
+	Servers []string `mapstructure:"servers"`
+	// JoinLeave posts a message when a player joins or leaves
+	JoinLeave bool `mapstructure:"join-leave"`
+	// PlayerCount is PlayerCountTopic, PlayerCountPresence or empty
+	PlayerCount string `mapstructure:"player-count"`
+	// TribeLog posts the tribe log of the servers
+	TribeLog bool `mapstructure:"tribe-log"`
+}
+
+// Notifier is a Bridge that posts notices such as join and leave events
+type Notifier interface {
+	Notify(ctx context.Context, route *Route, text string) (err error)
+}
+
+// StatusSetter is a Bridge that shows a status, the player count, of a route
+type StatusSetter interface {
+	SetStatus(ctx context.Context, route *Route, kind, status string) (err error)
+}
+
+// "2023.01.01_12.00.00: Tribe Foo, ID 123: Day 12, 08:21:34: Bob froze Rex"
+var patternTribeLog = regexp.MustCompile(`Tribe (.+?), ID \d+: (Day \d+, [\d:]+): (.*)$`)
+
+// SetEventsInterval sets how often the servers of the routes with Events are
+// polled with RCON
+func (cb *ChatBot) SetEventsInterval(interval time.Duration) {
+	cb.eventsInterval = interval
+}
+
+// serverEvent is a join, leave or tribe log event of a server
+type serverEvent struct {
+	tribeLog bool
+	text     string
+}
+
+// serverState is what the last poll saw on a server
+type serverState struct {
+	online  bool
+	players map[string]string // steam id => name
+}
+
+// eventServers returns the servers watched for the route
+func (cb *ChatBot) eventServers(route *Route) (servers []*rcon.Server) {
+	names := route.Events.Servers
+	if len(names) == 0 && route.ServerName != "" {
+		names = []string{route.ServerName}
+	}
+
+	for _, server := range cb.servers {
+		if len(names) == 0 {
+			servers = append(servers, server)
+			continue
+		}
+		for _, name := range names {
+			if strings.EqualFold(server.Name, name) {
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers
+}
+
+// watchServers polls the servers of the routes with Events until ctx is done
+func (cb *ChatBot) watchServers(ctx context.Context) {
+	var routes []*Route
+	watched := map[*rcon.Server]bool{}
+	tribeLog := map[*rcon.Server]bool{}
+
+	for _, route := range cb.routes {
+		if route.Events == nil {
+			continue
+		}
+		routes = append(routes, route)
+
+		for _, server := range cb.eventServers(route) {
+			watched[server] = true
+			if route.Events.TribeLog {
+				tribeLog[server] = true
+			}
+		}
+	}
+
+	if len(watched) == 0 {
+		return
+	}
+
+	states := map[*rcon.Server]*serverState{}
+	ticker := time.NewTicker(cb.eventsInterval)
+	defer ticker.Stop()
+
+	for {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		notices := map[*rcon.Server][]*serverEvent{}
+
+		for server := range watched {
+			server := server
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				state, events := cb.pollServer(server, states[server], tribeLog[server])
+
+				mu.Lock()
+				defer mu.Unlock()
+				states[server] = state
+				notices[server] = events
+			}()
+		}
+		wg.Wait()
+
+		for _, route := range routes {
+			cb.postEvents(ctx, route, states, notices)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollServer returns the new state of the server and its events since prev
+func (cb *ChatBot) pollServer(server *rcon.Server, prev *serverState, tribeLog bool) (state *serverState, events []*serverEvent) {
+	players, err := cb.listPlayers(server)
+	if err != nil {
+		if prev == nil || prev.online {
+			log.Warnf("[%v] ListPlayers failure: %v", server.Name, err)
+		}
+		// players are unknown, no leave events for them
+		if prev == nil {
+			return &serverState{}, nil
+		}
+		return &serverState{players: prev.players}, nil
+	}
+
+	state = &serverState{online: true, players: players}
+
+	// the first poll is the baseline
+	if prev != nil && prev.players != nil {
+		var joined, left []string
+		for id, name := range state.players {
+			if _, ok := prev.players[id]; !ok {
+				joined = append(joined, name)
+			}
+		}
+		for id, name := range prev.players {
+			if _, ok := state.players[id]; !ok {
+				left = append(left, name)
+			}
+		}
+		sort.Strings(joined)
+		sort.Strings(left)
+
+		for _, name := range joined {
+			events = append(events, &serverEvent{text: fmt.Sprintf("%v joined %v", name, server.Name)})
+		}
+		for _, name := range left {
+			events = append(events, &serverEvent{text: fmt.Sprintf("%v left %v", name, server.Name)})
+		}
+	}
+
+	if tribeLog {
+		out, err := cb.runRcon(server, []string{"GetGameLog"})
+		if err != nil {
+			log.Warnf("[%v] GetGameLog failure: %v", server.Name, err)
+			return state, events
+		}
+
+		for _, line := range strings.Split(out, "\n") {
+			match := patternTribeLog.FindStringSubmatch(strings.TrimSpace(line))
+			if match != nil {
+				events = append(events, &serverEvent{
+					tribeLog: true,
+					text:     fmt.Sprintf("[%v] Tribe %v: %v", server.Name, match[1], stripTribeLogTags(match[3])),
+				})
+			}
+		}
+	}
+
+	return state, events
+}
+
+// tribe log lines carry rich text tags such as <RichColor Color="1, 0, 0, 1">...</>
+var patternRichText = regexp.MustCompile(`<RichColor[^>]*>|</[^>]*>`)
+
+func stripTribeLogTags(s string) string {
+	return strings.TrimSpace(patternRichText.ReplaceAllString(s, ""))
+}
+
+func (cb *ChatBot) postEvents(ctx context.Context, route *Route, states map[*rcon.Server]*serverState, notices map[*rcon.Server][]*serverEvent) {
+	bridge := cb.bridge(route)
+	servers := cb.eventServers(route)
+
+	if notifier, ok := bridge.(Notifier); ok {
+		for _, server := range servers {
+			for _, event := range notices[server] {
+				if event.tribeLog && !route.Events.TribeLog || !event.tribeLog && !route.Events.JoinLeave {
+					continue
+				}
+
+				if err := notifier.Notify(ctx, route, event.text); err != nil {
+					log.Errorf("[%v] %v.Notify failure: %v", route, bridge.Name(), err)
+				}
+			}
+		}
+	}
+
+	if route.Events.PlayerCount == "" {
+		return
+	}
+
+	setter, ok := bridge.(StatusSetter)
+	if !ok {
+		return
+	}
+
+	if err := setter.SetStatus(ctx, route, route.Events.PlayerCount, playerCount(servers, states)); err != nil {
+		log.Errorf("[%v] %v.SetStatus failure: %v", route, bridge.Name(), err)
+	}
+}
+
+// playerCount is "N players online on <map>", with the count of each map
+// when there are several
+func playerCount(servers []*rcon.Server, states map[*rcon.Server]*serverState) string {
+	total := 0
+	var online, parts []string
+	for _, server := range servers {
+		state := states[server]
+		if state == nil || !state.online {
+			parts = append(parts, server.Name+" offline")
+			continue
+		}
+		total += len(state.players)
+		online = append(online, server.Name)
+		parts = append(parts, fmt.Sprintf("%v %v", server.Name, len(state.players)))
+	}
+
+	players := "players"
+	if total == 1 {
+		players = "player"
+	}
+
+	if len(servers) == 1 {
+		if len(online) == 0 {
+			return servers[0].Name + " is offline"
+		}
+		return fmt.Sprintf("%v %v online on %v", total, players, servers[0].Name)
+	}
+
+	return fmt.Sprintf("%v %v online (%v)", total, players, strings.Join(parts, ", "))
+}
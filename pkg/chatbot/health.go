@@ -0,0 +1,260 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// readyPollMax is how long a polled route may go without a successful
+	// LRANGE and still be ready, it polls every 500ms
+	readyPollMax = 10 * time.Second
+	// healthGrace is how long a bridge or route may be down before /healthz
+	// fails and the orchestrator restarts the bot
+	healthGrace = 5 * time.Minute
+	// backoffMax is the longest wait between retries of a failing delivery
+	// or bridge
+	backoffMax = time.Minute
+)
+
+// HealthChecker is a Bridge that reports its connection to the platform
+type HealthChecker interface {
+	// Connected returns whether the bridge is connected and since when it
+	// is, or is not
+	Connected() (ok bool, since time.Time)
+}
+
+// backoff is an exponential wait between retries
+type backoff struct {
+	min, max time.Duration
+	next     time.Duration
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{min: min, max: max, next: min}
+}
+
+func (b *backoff) reset() {
+	b.next = b.min
+}
+
+// wait sleeps the next wait, doubling it, or returns ctx.Err() when ctx is
+// done first
+func (b *backoff) wait(ctx context.Context) (err error) {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(b.next):
+	}
+
+	if b.next *= 2; b.next > b.max {
+		b.next = b.max
+	}
+	return nil
+}
+
+// health is the delivery state of the routes
+type health struct {
+	mu      sync.Mutex
+	started time.Time
+	routes  map[*Route]*routeHealth
+}
+
+type routeHealth struct {
+	// lastPoll is the last successful LRANGE of a polled route
+	lastPoll time.Time
+	// subscribed is whether a subscribed or queue route receives messages
+	// now, since changed
+	subscribed bool
+	changed    time.Time
+	lastError  string
+}
+
+// HealthStatus is the body of /healthz and /readyz
+type HealthStatus struct {
+	Status  string                   `json:"status"`
+	Bridges map[string]*BridgeHealth `json:"bridges"`
+	Routes  map[string]*RouteHealth  `json:"routes"`
+	Outbox  map[string]*OutboxStats  `json:"outbox"`
+}
+
+type BridgeHealth struct {
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"`
+}
+
+type RouteHealth struct {
+	Delivery   string     `json:"delivery"`
+	Ok         bool       `json:"ok"`
+	LastPoll   *time.Time `json:"last_poll,omitempty"`
+	Subscribed bool       `json:"subscribed,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+
+	// since is when the route went down
+	since time.Time
+}
+
+func newHealth(routes []*Route) *health {
+	h := &health{started: time.Now(), routes: map[*Route]*routeHealth{}}
+	for _, route := range routes {
+		h.routes[route] = &routeHealth{changed: h.started}
+	}
+	return h
+}
+
+func (h *health) polled(route *Route) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.routes[route].lastPoll = time.Now()
+}
+
+func (h *health) subscribed(route *Route, subscribed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := h.routes[route]
+	if state.subscribed != subscribed {
+		state.subscribed = subscribed
+		state.changed = time.Now()
+	}
+}
+
+func (h *health) failed(route *Route, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.routes[route].lastError = err.Error()
+}
+
+func (h *health) route(route *Route, now time.Time) *RouteHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := h.routes[route]
+	status := &RouteHealth{
+		Delivery:  route.Delivery,
+		LastError: state.lastError,
+	}
+
+	if route.Delivery != DeliveryPoll {
+		status.Subscribed = state.subscribed
+		status.Ok = state.subscribed
+		status.since = state.changed
+		return status
+	}
+
+	status.since = h.started
+	if !state.lastPoll.IsZero() {
+		lastPoll := state.lastPoll
+		status.LastPoll = &lastPoll
+		status.Ok = now.Sub(lastPoll) < readyPollMax
+		status.since = lastPoll
+	}
+	return status
+}
+
+// healthStatus returns the state of the bridges and routes, whether all of
+// them are up and whether none has been down longer than healthGrace
+func (cb *ChatBot) healthStatus() (status *HealthStatus, ready, alive bool) {
+	now := time.Now()
+	ready, alive = true, true
+
+	status = &HealthStatus{
+		Bridges: map[string]*BridgeHealth{},
+		Routes:  map[string]*RouteHealth{},
+		Outbox:  cb.Stats(),
+	}
+
+	for _, bridge := range cb.bridges {
+		checker, ok := bridge.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		connected, since := checker.Connected()
+		status.Bridges[bridge.Name()] = &BridgeHealth{Connected: connected, Since: since}
+		if !connected {
+			ready = false
+			if now.Sub(since) > healthGrace {
+				alive = false
+			}
+		}
+	}
+
+	for _, route := range cb.routes {
+		state := cb.health.route(route, now)
+		status.Routes[route.String()] = state
+		if !state.Ok {
+			ready = false
+			if now.Sub(state.since) > healthGrace {
+				alive = false
+			}
+		}
+	}
+
+	return status, ready, alive
+}
+
+// SetHealthAddr serves /healthz and /readyz on addr. /readyz fails while a
+// bridge is disconnected or a route is not delivering, /healthz once that
+// lasts longer than healthGrace.
+func (cb *ChatBot) SetHealthAddr(addr string) {
+	cb.healthAddr = addr
+}
+
+func (cb *ChatBot) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status, ready, alive := cb.healthStatus()
+
+	ok := alive
+	if r.URL.Path == "/readyz" {
+		ok = ready
+	}
+
+	code := http.StatusOK
+	status.Status = "ok"
+	if !ok {
+		code = http.StatusServiceUnavailable
+		status.Status = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Debugf("health response failure: %v", err)
+	}
+}
+
+// serveHealth serves the health endpoints until ctx is done
+func (cb *ChatBot) serveHealth(ctx context.Context) (err error) {
+	ln, err := net.Listen("tcp", cb.healthAddr)
+	if err != nil {
+		return errors.Wrapf(err, "net.Listen(%v)", cb.healthAddr)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", cb.handleHealth)
+	mux.HandleFunc("/readyz", cb.handleHealth)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf("health http.Serve failure: %v", err)
+		}
+	}()
+
+	log.Infof("health endpoints on %v", cb.healthAddr)
+	return nil
+}
@@ -0,0 +1,245 @@
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// HttpBridge is a generic webhook Bridge. In-game messages are POSTed as
+// json to url, messages POSTed to /message on listen are sent to the game.
+type HttpBridge struct {
+	name   string
+	url    string
+	listen string
+	secret string
+
+	client *http.Client
+	server *http.Server
+	routes []*Route
+	recv   func(msg *BridgeMessage)
+}
+
+// HttpMessage is an in-game message POSTed to the url of the HttpBridge
+type HttpMessage struct {
+	Time     time.Time `json:"time"`
+	Server   string    `json:"server"`
+	Tribe    string    `json:"tribe"`
+	Survivor string    `json:"survivor"`
+	Message  string    `json:"message"`
+}
+
+// HttpPayload is the body POSTed to the url of the HttpBridge
+type HttpPayload struct {
+	Channel  string         `json:"channel"`
+	Messages []*HttpMessage `json:"messages,omitempty"`
+	// Notice is an event such as a player joining
+	Notice string `json:"notice,omitempty"`
+}
+
+// HttpIncoming is the body POSTed to /message of the HttpBridge
+type HttpIncoming struct {
+	Channel string `json:"channel"`
+	Tribe   string `json:"tribe"`
+	Player  string `json:"player"`
+	Content string `json:"content"`
+}
+
+// httpBatchMax is the number of messages of one POST
+const httpBatchMax = 100
+
+func NewHttpBridge(name, url, listen, secret string) *HttpBridge {
+	return &HttpBridge{
+		name:   name,
+		url:    url,
+		listen: listen,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (h *HttpBridge) Name() string {
+	return h.name
+}
+
+func (h *HttpBridge) Open(ctx context.Context, routes []*Route, recv func(msg *BridgeMessage)) (err error) {
+	h.routes = routes
+	h.recv = recv
+
+	if h.listen == "" {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", h.listen)
+	if err != nil {
+		return errors.Wrapf(err, "net.Listen(%v)", h.listen)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/message", h.handleMessage)
+	h.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := h.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf("[%v] http.Serve failure: %v", h.name, err)
+		}
+	}()
+
+	log.Infof("[%v] listen %v", h.name, h.listen)
+	return nil
+}
+
+func (h *HttpBridge) Close() (err error) {
+	if h.server == nil {
+		return nil
+	}
+	return h.server.Close()
+}
+
+func (h *HttpBridge) authorized(r *http.Request) bool {
+	if h.secret == "" {
+		return false
+	}
+	got := []byte(r.Header.Get("Authorization"))
+	want := []byte("Bearer " + h.secret)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func (h *HttpBridge) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var in HttpIncoming
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&in); err != nil {
+		http.Error(w, fmt.Sprintf("invalid message: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	found := false
+	for _, route := range h.routes {
+		if route.ChannelId == in.Channel {
+			found = true
+		}
+	}
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown channel %q", in.Channel), http.StatusNotFound)
+		return
+	}
+
+	content := strings.Join(strings.Fields(in.Content), " ")
+	if content == "" || in.Player == "" {
+		http.Error(w, "player and content are required", http.StatusBadRequest)
+		return
+	}
+
+	h.recv(&BridgeMessage{
+		Bridge:    h.name,
+		ChannelId: in.Channel,
+		UserId:    in.Player,
+		Tribe:     in.Tribe,
+		Player:    in.Player,
+		Content:   content,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HttpBridge) Send(ctx context.Context, route *Route, msgs []*RedisMessage) (n int, err error) {
+	if len(msgs) > httpBatchMax {
+		msgs = msgs[:httpBatchMax]
+	}
+
+	if h.url == "" {
+		return len(msgs), nil
+	}
+
+	payload := &HttpPayload{Channel: route.ChannelId}
+	for _, msg := range msgs {
+		sec := int64(msg.Epoch)
+		payload.Messages = append(payload.Messages, &HttpMessage{
+			Time:     time.Unix(sec, int64((msg.Epoch-float64(sec))*1e9)).UTC(),
+			Server:   msg.ServerName,
+			Tribe:    msg.TribeName,
+			Survivor: msg.SurvivorName,
+			Message:  msg.Message,
+		})
+	}
+
+	return len(msgs), h.send(ctx, payload)
+}
+
+func (h *HttpBridge) Notify(ctx context.Context, route *Route, text string) (err error) {
+	if h.url == "" {
+		return nil
+	}
+	return h.send(ctx, &HttpPayload{Channel: route.ChannelId, Notice: text})
+}
+
+// send POSTs the payload, retrying with backoff
+func (h *HttpBridge) send(ctx context.Context, payload *HttpPayload) (err error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = h.post(ctx, b)
+		if err == nil || attempt == sendAttempts {
+			return err
+		}
+
+		log.Debugf("[%v] post attempt %v failure, retry in %v: %v", h.name, attempt, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (h *HttpBridge) post(ctx context.Context, body []byte) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "http.NewRequest")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+h.secret)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "POST %v", h.url)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("POST %v: %v", h.url, resp.Status)
+	}
+	return nil
+}
+
+func (h *HttpBridge) Retain(ctx context.Context, route *Route) (err error) {
+	return nil
+}
@@ -0,0 +1,145 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpBridgeHandleMessage(t *testing.T) {
+	var msgs []*BridgeMessage
+	h := NewHttpBridge("web", "", "", "secret")
+	h.routes = []*Route{{ChannelId: "general"}}
+	h.recv = func(msg *BridgeMessage) { msgs = append(msgs, msg) }
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		auth   string
+		body   string
+		status int
+		msg    *BridgeMessage
+	}{
+		{
+			name:   "ok",
+			method: http.MethodPost,
+			auth:   "Bearer secret",
+			body:   `{"channel":"general","tribe":"Raptors","player":"Bob","content":" hello\n there "}`,
+			status: http.StatusNoContent,
+			msg:    &BridgeMessage{Bridge: "web", ChannelId: "general", UserId: "Bob", Tribe: "Raptors", Player: "Bob", Content: "hello there"},
+		},
+		{name: "get", method: http.MethodGet, auth: "Bearer secret", status: http.StatusMethodNotAllowed},
+		{name: "no auth", method: http.MethodPost, body: `{}`, status: http.StatusUnauthorized},
+		{name: "wrong secret", method: http.MethodPost, auth: "Bearer secreT", body: `{}`, status: http.StatusUnauthorized},
+		{name: "not bearer", method: http.MethodPost, auth: "secret", body: `{}`, status: http.StatusUnauthorized},
+		{name: "invalid json", method: http.MethodPost, auth: "Bearer secret", body: `{"channel":`, status: http.StatusBadRequest},
+		{name: "unknown channel", method: http.MethodPost, auth: "Bearer secret", body: `{"channel":"other","player":"Bob","content":"hi"}`, status: http.StatusNotFound},
+		{name: "no player", method: http.MethodPost, auth: "Bearer secret", body: `{"channel":"general","content":"hi"}`, status: http.StatusBadRequest},
+		{name: "blank content", method: http.MethodPost, auth: "Bearer secret", body: `{"channel":"general","player":"Bob","content":" \n "}`, status: http.StatusBadRequest},
+	} {
+		msgs = nil
+
+		req := httptest.NewRequest(tc.method, "/message", strings.NewReader(tc.body))
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		w := httptest.NewRecorder()
+		h.handleMessage(w, req)
+
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		if tc.msg != nil {
+			assert.Equal(t, []*BridgeMessage{tc.msg}, msgs, tc.name)
+		} else {
+			assert.Empty(t, msgs, tc.name)
+		}
+	}
+
+	// without a secret nothing is accepted
+	h.secret = ""
+	req := httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	h.handleMessage(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestHttpBridgeSend(t *testing.T) {
+	payloads := make(chan *HttpPayload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var payload HttpPayload
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads <- &payload
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	h := NewHttpBridge("web", server.URL, "", "secret")
+	route := &Route{ChannelId: "general"}
+
+	n, err := h.Send(context.Background(), route, []*RedisMessage{
+		{Epoch: 1700000000.5, ServerName: "Island", TribeName: "Raptors", SurvivorName: "Bob", Message: "hello"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, &HttpPayload{Channel: "general", Messages: []*HttpMessage{{
+		Time:     time.Unix(1700000000, 5e8).UTC(),
+		Server:   "Island",
+		Tribe:    "Raptors",
+		Survivor: "Bob",
+		Message:  "hello",
+	}}}, <-payloads)
+
+	// a batch is at most httpBatchMax messages
+	msgs := make([]*RedisMessage, httpBatchMax+5)
+	for i := range msgs {
+		msgs[i] = &RedisMessage{Message: "m"}
+	}
+	n, err = h.Send(context.Background(), route, msgs)
+	assert.Nil(t, err)
+	assert.Equal(t, httpBatchMax, n)
+	assert.Len(t, (<-payloads).Messages, httpBatchMax)
+
+	assert.Nil(t, h.Notify(context.Background(), route, "Bob joined"))
+	assert.Equal(t, &HttpPayload{Channel: "general", Notice: "Bob joined"}, <-payloads)
+
+	// without url the messages are dropped
+	h.url = ""
+	n, err = h.Send(context.Background(), route, msgs[:2])
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Nil(t, h.Notify(context.Background(), route, "Bob left"))
+	assert.Empty(t, payloads)
+}
+
+func TestHttpBridgeSendFailure(t *testing.T) {
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	h := NewHttpBridge("web", server.URL, "", "")
+
+	// the retry backoff gives up when ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	n, err := h.Send(ctx, &Route{ChannelId: "general"}, []*RedisMessage{{Message: "hello"}})
+	assert.Equal(t, 1, n)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, posts)
+
+	err = h.post(context.Background(), []byte(`{}`))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "502")
+	}
+}
@@ -0,0 +1,195 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// identityNameMax is the longest survivor or tribe name accepted by /link
+const identityNameMax = 32
+
+// "[Tribe] Survivor", the nickname convention of the discord members
+var patternTribeNick = regexp.MustCompile(`^\s*\[([^\[\]]*)\]\s*(.+)$`)
+
+// Identity is the in-game survivor a discord user linked with /link
+type Identity struct {
+	Survivor string    `json:"survivor"`
+	Tribe    string    `json:"tribe,omitempty"`
+	Linked   time.Time `json:"linked"`
+}
+
+// identityStore keeps the identity of each discord user id, persisted to path
+type identityStore struct {
+	path string
+
+	mu         sync.Mutex
+	identities map[string]*Identity
+}
+
+func newIdentityStore(path string) *identityStore {
+	return &identityStore{
+		path:       path,
+		identities: map[string]*Identity{},
+	}
+}
+
+func (store *identityStore) load() (err error) {
+	b, err := ioutil.ReadFile(store.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "ioutil.ReadFile(%v)", store.path)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := json.Unmarshal(b, &store.identities); err != nil {
+		return errors.Wrapf(err, "json.Unmarshal(%v)", store.path)
+	}
+	return nil
+}
+
+func (store *identityStore) get(userId string) *Identity {
+	if store == nil {
+		return nil
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.identities[userId]
+}
+
+// set links userId to identity, or unlinks it when identity is nil. A
+// survivor is linked to one user only.
+func (store *identityStore) set(userId string, identity *Identity) (err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if identity != nil {
+		for id, other := range store.identities {
+			if id != userId && strings.EqualFold(other.Survivor, identity.Survivor) {
+				return errors.Errorf("survivor %q is linked to another user", identity.Survivor)
+			}
+		}
+		store.identities[userId] = identity
+	} else {
+		delete(store.identities, userId)
+	}
+
+	b, err := json.MarshalIndent(store.identities, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	return writeFile(store.path, b)
+}
+
+// SetIdentities enables /link and /unlink, the survivors linked by the
+// discord users are persisted to path and replace their nicknames in game
+func (cb *ChatBot) SetIdentities(path string) (err error) {
+	store := newIdentityStore(path)
+	if err := store.load(); err != nil {
+		return errors.Wrap(err, "store.load")
+	}
+	cb.identities = store
+	return nil
+}
+
+// identityName collapses the spaces of a survivor or tribe name
+func identityName(kind, name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if utf8.RuneCountInString(name) > identityNameMax {
+		return "", errors.Errorf("%v name is longer than %v characters", kind, identityNameMax)
+	}
+	if strings.ContainsAny(name, "[]") {
+		return "", errors.Errorf("%v name may not contain brackets", kind)
+	}
+	return name, nil
+}
+
+// link is the /link command
+func (cb *ChatBot) link(user *discordgo.User, opts map[string]string) (content string, err error) {
+	if cb.identities == nil {
+		return "", errors.Errorf("linking is disabled")
+	}
+
+	survivor, err := identityName("survivor", opts["survivor"])
+	if err != nil {
+		return "", err
+	}
+	if survivor == "" {
+		return "", errors.Errorf("survivor name is empty")
+	}
+
+	tribe, err := identityName("tribe", opts["tribe"])
+	if err != nil {
+		return "", err
+	}
+
+	identity := &Identity{Survivor: survivor, Tribe: tribe, Linked: time.Now()}
+	if err := cb.identities.set(user.ID, identity); err != nil {
+		return "", err
+	}
+
+	if tribe == "" {
+		return fmt.Sprintf("Linked to survivor %v.", survivor), nil
+	}
+	return fmt.Sprintf("Linked to survivor %v of tribe %v.", survivor, tribe), nil
+}
+
+// unlink is the /unlink command
+func (cb *ChatBot) unlink(user *discordgo.User, opts map[string]string) (content string, err error) {
+	if cb.identities == nil {
+		return "", errors.Errorf("linking is disabled")
+	}
+
+	if cb.identities.get(user.ID) == nil {
+		return "You are not linked to a survivor.", nil
+	}
+
+	if err := cb.identities.set(user.ID, nil); err != nil {
+		return "", err
+	}
+	return "Unlinked, your nickname is used in game again.", nil
+}
+
+// parseNick splits a "[Tribe] Survivor" nickname, the whole nickname is the
+// survivor otherwise
+func parseNick(nick string) (tribe, player string) {
+	if match := patternTribeNick.FindStringSubmatch(nick); match != nil {
+		return strings.TrimSpace(match[1]), strings.TrimSpace(match[2])
+	}
+	return "", strings.TrimSpace(nick)
+}
+
+// displayName is the server nickname of the author of m, else its global
+// display name, else its username
+func displayName(s *discordgo.Session, m *discordgo.MessageCreate) string {
+	if m.Member != nil && m.Member.Nick != "" {
+		return m.Member.Nick
+	}
+
+	if m.GuildID != "" && s.State != nil {
+		if member, err := s.State.Member(m.GuildID, m.Author.ID); err == nil && member.Nick != "" {
+			return member.Nick
+		}
+	}
+
+	if m.Author.GlobalName != "" {
+		return m.Author.GlobalName
+	}
+	return m.Author.Username
+}
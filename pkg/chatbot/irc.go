@@ -0,0 +1,316 @@
+package chatbot
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ircLineMax keeps a PRIVMSG within the 512 bytes of an irc line
+	ircLineMax = 400
+	// ircLineDelay paces the lines sent, servers kick flooding clients
+	ircLineDelay = 500 * time.Millisecond
+)
+
+// ircFormatting are the mIRC color and formatting codes
+var ircFormatting = regexp.MustCompile(`\x03(\d{1,2}(,\d{1,2})?)?|[\x02\x0f\x16\x1d\x1e\x1f]`)
+
+// Irc is the Bridge of an irc network, the channel of a route is an irc
+// channel such as #ark
+type Irc struct {
+	name      string
+	addr      string
+	nick      string
+	password  string
+	tlsConfig *tls.Config
+
+	routes []*Route
+	recv   func(msg *BridgeMessage)
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	conn net.Conn
+	// current is the nick given by the server, nick may be in use
+	current string
+	// changed is when conn was set or cleared
+	changed time.Time
+}
+
+func NewIrc(name, addr, nick, password string, tlsConfig *tls.Config) *Irc {
+	if nick == "" {
+		nick = "arktools"
+	}
+
+	return &Irc{
+		name:      name,
+		addr:      addr,
+		nick:      nick,
+		password:  password,
+		tlsConfig: tlsConfig,
+	}
+}
+
+func (irc *Irc) Name() string {
+	return irc.name
+}
+
+// Open connects in the background, reconnecting until ctx is done
+func (irc *Irc) Open(ctx context.Context, routes []*Route, recv func(msg *BridgeMessage)) (err error) {
+	irc.routes = routes
+	irc.recv = recv
+
+	ctx, irc.cancel = context.WithCancel(ctx)
+
+	irc.mu.Lock()
+	irc.changed = time.Now()
+	irc.mu.Unlock()
+
+	go func() {
+		retry := newBackoff(time.Second, backoffMax)
+		for ctx.Err() == nil {
+			started := time.Now()
+			if err := irc.session(ctx); err != nil && ctx.Err() == nil {
+				log.Errorf("[%v] irc session failure: %v", irc.name, err)
+			}
+
+			if time.Since(started) > backoffMax {
+				retry.reset()
+			}
+			retry.wait(ctx)
+		}
+	}()
+
+	return nil
+}
+
+func (irc *Irc) Close() (err error) {
+	if irc.cancel != nil {
+		irc.cancel()
+	}
+	return nil
+}
+
+func (irc *Irc) Connected() (ok bool, since time.Time) {
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+	return irc.conn != nil, irc.changed
+}
+
+func (irc *Irc) session(ctx context.Context) (err error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+
+	var conn net.Conn
+	if irc.tlsConfig != nil {
+		config := irc.tlsConfig.Clone()
+		if config.ServerName == "" {
+			if host, _, err := net.SplitHostPort(irc.addr); err == nil {
+				config.ServerName = host
+			}
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: config}).DialContext(ctx, "tcp", irc.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", irc.addr)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "dial(%v)", irc.addr)
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	irc.mu.Lock()
+	irc.conn = conn
+	irc.current = irc.nick
+	irc.changed = time.Now()
+	irc.mu.Unlock()
+
+	defer func() {
+		irc.mu.Lock()
+		irc.conn = nil
+		irc.changed = time.Now()
+		irc.mu.Unlock()
+	}()
+
+	if irc.password != "" {
+		if err := irc.write("PASS " + irc.password); err != nil {
+			return err
+		}
+	}
+	if err := irc.write("NICK " + irc.nick); err != nil {
+		return err
+	}
+	if err := irc.write(fmt.Sprintf("USER %v 0 * :arktools chatbot", irc.nick)); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		// servers PING every few minutes
+		conn.SetReadDeadline(time.Now().Add(10 * time.Minute))
+
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return errors.Wrap(err, "irc read")
+		}
+
+		if err := irc.handle(strings.TrimRight(line, "\r\n")); err != nil {
+			return err
+		}
+	}
+}
+
+// handle processes a line ":prefix COMMAND params :trailing"
+func (irc *Irc) handle(line string) (err error) {
+	var prefix string
+	if strings.HasPrefix(line, ":") {
+		pos := strings.Index(line, " ")
+		if pos == -1 {
+			return nil
+		}
+		prefix, line = line[1:pos], line[pos+1:]
+	}
+
+	var trailing string
+	if pos := strings.Index(line, " :"); pos != -1 {
+		line, trailing = line[:pos], line[pos+2:]
+	}
+
+	params := strings.Fields(line)
+	if len(params) == 0 {
+		return nil
+	}
+
+	switch strings.ToUpper(params[0]) {
+	case "PING":
+		return irc.write("PONG :" + trailing)
+	case "001":
+		// welcome, registered
+		var channels []string
+		for _, route := range irc.routes {
+			channels = append(channels, route.ChannelId)
+		}
+		if len(channels) == 0 {
+			log.Infof("[%v] connected to %v, no channel to join", irc.name, irc.addr)
+			return nil
+		}
+		log.Infof("[%v] connected to %v, join %v", irc.name, irc.addr, strings.Join(channels, ","))
+		return irc.write("JOIN " + strings.Join(channels, ","))
+	case "433":
+		// nickname is already in use
+		irc.mu.Lock()
+		irc.current += "_"
+		nick := irc.current
+		irc.mu.Unlock()
+		return irc.write("NICK " + nick)
+	case "PRIVMSG":
+		if len(params) < 2 {
+			return nil
+		}
+		irc.privmsg(prefix, params[1], trailing)
+	}
+
+	return nil
+}
+
+func (irc *Irc) privmsg(prefix, target, text string) {
+	nick := prefix
+	if pos := strings.Index(nick, "!"); pos != -1 {
+		nick = nick[:pos]
+	}
+
+	var channel string
+	for _, route := range irc.routes {
+		if strings.EqualFold(route.ChannelId, target) {
+			channel = route.ChannelId
+		}
+	}
+	if channel == "" {
+		return
+	}
+
+	// CTCP ACTION of /me
+	if strings.HasPrefix(text, "\x01ACTION ") {
+		text = "* " + nick + " " + strings.Trim(text[len("\x01ACTION "):], "\x01")
+	} else if strings.HasPrefix(text, "\x01") {
+		return
+	}
+
+	text = strings.Join(strings.Fields(ircFormatting.ReplaceAllString(text, "")), " ")
+	if text == "" {
+		return
+	}
+
+	irc.recv(&BridgeMessage{
+		Bridge:    irc.name,
+		ChannelId: channel,
+		UserId:    nick,
+		Player:    nick,
+		Content:   text,
+	})
+}
+
+func (irc *Irc) write(line string) (err error) {
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+
+	if irc.conn == nil {
+		return errors.Errorf("irc %v is not connected", irc.addr)
+	}
+
+	irc.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := irc.conn.Write([]byte(line + "\r\n")); err != nil {
+		return errors.Wrap(err, "irc write")
+	}
+	return nil
+}
+
+func (irc *Irc) Send(ctx context.Context, route *Route, msgs []*RedisMessage) (n int, err error) {
+	for i, msg := range msgs {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return i, ctx.Err()
+			case <-time.After(ircLineDelay):
+			}
+		}
+
+		text := fmt.Sprintf("[%v][%v] %v: %v", msg.ServerName, msg.TribeName, msg.SurvivorName, msg.Message)
+		text = strings.Join(strings.Fields(text), " ")
+
+		if err := irc.write(fmt.Sprintf("PRIVMSG %v :%v", route.ChannelId, truncate(text, ircLineMax))); err != nil {
+			return i + 1, errors.Wrap(err, "PRIVMSG")
+		}
+	}
+
+	return len(msgs), nil
+}
+
+func (irc *Irc) Notify(ctx context.Context, route *Route, text string) (err error) {
+	text = strings.Join(strings.Fields(text), " ")
+	if err := irc.write(fmt.Sprintf("NOTICE %v :%v", route.ChannelId, truncate(text, ircLineMax))); err != nil {
+		return errors.Wrap(err, "NOTICE")
+	}
+	return nil
+}
+
+func (irc *Irc) Retain(ctx context.Context, route *Route) (err error) {
+	return nil
+}
@@ -0,0 +1,175 @@
+package chatbot
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pipeIrc returns an Irc connected to the client end of a net.Pipe and the
+// lines it writes to the server end
+func pipeIrc(t *testing.T, routes []*Route) (irc *Irc, lines chan string, msgs chan *BridgeMessage) {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	lines = make(chan string, 16)
+	go func() {
+		defer close(lines)
+		r := bufio.NewReader(server)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- strings.TrimSuffix(line, "\r\n")
+		}
+	}()
+
+	msgs = make(chan *BridgeMessage, 16)
+	irc = NewIrc("irc", "irc.example.org:6667", "", "", nil)
+	irc.routes = routes
+	irc.recv = func(msg *BridgeMessage) { msgs <- msg }
+	irc.conn = client
+	irc.current = irc.nick
+
+	return irc, lines, msgs
+}
+
+func TestIrcHandle(t *testing.T) {
+	irc, lines, msgs := pipeIrc(t, []*Route{{ChannelId: "#ark"}, {ChannelId: "#Ark-Admin"}})
+
+	for _, tc := range []struct {
+		line  string
+		reply string
+	}{
+		{"PING :irc.example.org", "PONG :irc.example.org"},
+		{"ping :abc def", "PONG :abc def"},
+		{":irc.example.org PING :tok", "PONG :tok"},
+		{":irc.example.org 001 arktools :Welcome to the network", "JOIN #ark,#Ark-Admin"},
+		{":irc.example.org 433 * arktools :Nickname is already in use", "NICK arktools_"},
+		{":irc.example.org 433 * arktools_ :Nickname is already in use", "NICK arktools__"},
+	} {
+		assert.Nil(t, irc.handle(tc.line), tc.line)
+		assert.Equal(t, tc.reply, <-lines, tc.line)
+	}
+
+	for _, tc := range []struct {
+		line string
+		msg  *BridgeMessage
+	}{
+		{":bob!~bob@host PRIVMSG #ark :hello  there", &BridgeMessage{Bridge: "irc", ChannelId: "#ark", UserId: "bob", Player: "bob", Content: "hello there"}},
+		{":bob!~bob@host PRIVMSG #ark-admin :hi", &BridgeMessage{Bridge: "irc", ChannelId: "#Ark-Admin", UserId: "bob", Player: "bob", Content: "hi"}},
+		{":bob!~bob@host PRIVMSG #ark :\x01ACTION waves\x01", &BridgeMessage{Bridge: "irc", ChannelId: "#ark", UserId: "bob", Player: "bob", Content: "* bob waves"}},
+		{":bob PRIVMSG #ark :\x0304,01red\x03 and \x02bold\x02", &BridgeMessage{Bridge: "irc", ChannelId: "#ark", UserId: "bob", Player: "bob", Content: "red and bold"}},
+		// ignored: other channels, private messages, CTCP, formatting only
+		{":bob!~bob@host PRIVMSG #other :hello", nil},
+		{":bob!~bob@host PRIVMSG arktools :hello", nil},
+		{":bob!~bob@host PRIVMSG #ark :\x01VERSION\x01", nil},
+		{":bob!~bob@host PRIVMSG #ark :\x02\x02", nil},
+		{":bob!~bob@host PRIVMSG", nil},
+		// malformed and unknown lines
+		{":irc.example.org", nil},
+		{"", nil},
+		{":irc.example.org NOTICE * :*** Looking up your hostname", nil},
+	} {
+		assert.Nil(t, irc.handle(tc.line), "%q", tc.line)
+
+		var msg *BridgeMessage
+		select {
+		case msg = <-msgs:
+		default:
+		}
+		assert.Equal(t, tc.msg, msg, "%q", tc.line)
+	}
+
+	// nothing is written but the replies above
+	assert.Nil(t, irc.write("MARK"))
+	assert.Equal(t, "MARK", <-lines)
+}
+
+func TestIrcNoChannels(t *testing.T) {
+	irc, lines, _ := pipeIrc(t, nil)
+
+	// registered without routes, no JOIN of no channel
+	assert.Nil(t, irc.handle(":irc.example.org 001 arktools :Welcome"))
+	assert.Nil(t, irc.write("MARK"))
+	assert.Equal(t, "MARK", <-lines)
+}
+
+func TestIrcSend(t *testing.T) {
+	route := &Route{ChannelId: "#ark"}
+	irc, lines, _ := pipeIrc(t, []*Route{route})
+
+	n, err := irc.Send(context.Background(), route, []*RedisMessage{
+		{ServerName: "Island", TribeName: "Raptors", SurvivorName: "Bob", Message: "hello\r\nQUIT"},
+		{ServerName: "Island", SurvivorName: "Alice", Message: strings.Repeat("a", 500)},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "PRIVMSG #ark :[Island][Raptors] Bob: hello QUIT", <-lines)
+	assert.Equal(t, ircLineMax, len(strings.TrimPrefix(<-lines, "PRIVMSG #ark :")))
+
+	assert.Nil(t, irc.Notify(context.Background(), route, "Bob joined\nthe game"))
+	assert.Equal(t, "NOTICE #ark :Bob joined the game", <-lines)
+
+	// not connected
+	irc.conn = nil
+	n, err = irc.Send(context.Background(), route, []*RedisMessage{{Message: "hello"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestIrcSession(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan *BridgeMessage, 1)
+	irc := NewIrc("irc", ln.Addr().String(), "bot", "secret", nil)
+	assert.Nil(t, irc.Open(context.Background(), []*Route{{ChannelId: "#ark"}}, func(msg *BridgeMessage) { msgs <- msg }))
+	defer irc.Close()
+
+	conn, err := ln.Accept()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+
+	expect := func(line string) {
+		got, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, line+"\r\n", got)
+	}
+	reply := func(line string) {
+		_, err := conn.Write([]byte(line + "\r\n"))
+		assert.Nil(t, err)
+	}
+
+	expect("PASS secret")
+	expect("NICK bot")
+	expect("USER bot 0 * :arktools chatbot")
+
+	reply(":irc.example.org 001 bot :Welcome")
+	expect("JOIN #ark")
+
+	reply("PING :12345")
+	expect("PONG :12345")
+
+	reply(":bob!~bob@host PRIVMSG #ark :hello")
+	assert.Equal(t, "hello", (<-msgs).Content)
+
+	ok, _ := irc.Connected()
+	assert.True(t, ok)
+}
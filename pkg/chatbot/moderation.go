@@ -0,0 +1,307 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// directions of moderation
+const (
+	// ToGame is a message from a bridge to the game
+	ToGame = "to-game"
+	// ToBridge is an in-game message to the bridges
+	ToBridge = "to-bridge"
+)
+
+// actions of a ModerationRule
+const (
+	ActionReplace = "replace"
+	ActionDrop    = "drop"
+)
+
+// Moderation filters the messages relayed in both directions
+type Moderation struct {
+	Rules []*ModerationRule `mapstructure:"rules"`
+	// Muted are discord user ids, irc nicks or survivor names whose
+	// messages are dropped
+	Muted []string `mapstructure:"muted"`
+	// Spam drops the messages of a user flooding the chat
+	Spam *Spam `mapstructure:"spam"`
+	// Log appends what was filtered to this file as json lines
+	Log string `mapstructure:"log"`
+}
+
+// ModerationRule is a blocklist of words or a regular expression, not both
+type ModerationRule struct {
+	// Words match whole words, case insensitive
+	Words []string `mapstructure:"words"`
+	// Regex is a regular expression, (?i) makes it case insensitive
+	Regex string `mapstructure:"regex"`
+	// Action is ActionReplace (default) or ActionDrop
+	Action string `mapstructure:"action"`
+	// Replacement replaces the matches, *** by default
+	Replacement string `mapstructure:"replacement"`
+	// Direction is ToGame, ToBridge or empty for both
+	Direction string `mapstructure:"direction"`
+
+	pattern *regexp.Regexp
+}
+
+// Spam limits the messages of one user within Window
+type Spam struct {
+	Window time.Duration `mapstructure:"window"`
+	// MaxRepeats is the number of identical messages allowed
+	MaxRepeats int `mapstructure:"max-repeats"`
+	// MaxMessages is the number of messages allowed
+	MaxMessages int `mapstructure:"max-messages"`
+}
+
+type moderator struct {
+	config *Moderation
+	muted  map[string]bool
+
+	mu      sync.Mutex
+	history map[string][]*sent
+	// verdicts of in-game messages by hash, every route of a cluster sees them
+	verdicts map[string]*verdict
+}
+
+type sent struct {
+	at      time.Time
+	content string
+}
+
+type verdict struct {
+	at      time.Time
+	content string
+	ok      bool
+}
+
+// moderationEntry is a line of the moderation log
+type moderationEntry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	UserId    string    `json:"user_id,omitempty"`
+	User      string    `json:"user"`
+	Content   string    `json:"content"`
+	Result    string    `json:"result,omitempty"`
+	Reason    string    `json:"reason"`
+}
+
+// SetModeration filters the relayed messages with config
+func (cb *ChatBot) SetModeration(config *Moderation) (err error) {
+	mod := &moderator{
+		config:   config,
+		muted:    map[string]bool{},
+		history:  map[string][]*sent{},
+		verdicts: map[string]*verdict{},
+	}
+
+	for _, name := range config.Muted {
+		mod.muted[strings.ToLower(name)] = true
+	}
+
+	for idx, rule := range config.Rules {
+		switch rule.Action {
+		case "":
+			rule.Action = ActionReplace
+		case ActionReplace, ActionDrop:
+		default:
+			return errors.Errorf("moderation rule %v: unknown action: %v", idx, rule.Action)
+		}
+
+		switch rule.Direction {
+		case "", ToGame, ToBridge:
+		default:
+			return errors.Errorf("moderation rule %v: unknown direction: %v", idx, rule.Direction)
+		}
+
+		if rule.Replacement == "" {
+			rule.Replacement = "***"
+		}
+
+		if len(rule.Words) > 0 && rule.Regex != "" {
+			return errors.Errorf("moderation rule %v: both words and regex, make them two rules", idx)
+		}
+
+		expr := rule.Regex
+		if len(rule.Words) > 0 {
+			var words []string
+			for _, word := range rule.Words {
+				words = append(words, wordPattern(word))
+			}
+			expr = `(?i)(` + strings.Join(words, "|") + `)`
+		}
+		if expr == "" {
+			return errors.Errorf("moderation rule %v: neither words nor regex", idx)
+		}
+
+		rule.pattern, err = regexp.Compile(expr)
+		if err != nil {
+			return errors.Wrapf(err, "moderation rule %v", idx)
+		}
+	}
+
+	if config.Spam != nil && config.Spam.Window <= 0 {
+		config.Spam.Window = time.Minute
+	}
+
+	cb.moderator = mod
+	return nil
+}
+
+// wordPattern matches word as a whole word, \b only holds next to a word
+// character so it is left out at the edges of "c++"
+func wordPattern(word string) string {
+	expr := regexp.QuoteMeta(word)
+	if word == "" {
+		return expr
+	}
+	if isWordChar(word[0]) {
+		expr = `\b` + expr
+	}
+	if isWordChar(word[len(word)-1]) {
+		expr += `\b`
+	}
+	return expr
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// filter returns the content to relay and whether to relay it at all. key
+// identifies a message seen on several routes, empty when seen once.
+func (mod *moderator) filter(direction, key, userId, user, content string) (result string, ok bool) {
+	if mod == nil {
+		return content, true
+	}
+
+	mod.mu.Lock()
+	defer mod.mu.Unlock()
+
+	now := time.Now()
+
+	if key != "" {
+		if v := mod.verdicts[key]; v != nil {
+			return v.content, v.ok
+		}
+
+		defer func() {
+			mod.verdicts[key] = &verdict{at: now, content: result, ok: ok}
+		}()
+
+		// forget the verdicts no route asks for anymore
+		for k, v := range mod.verdicts {
+			if now.Sub(v.at) > 10*time.Minute {
+				delete(mod.verdicts, k)
+			}
+		}
+	}
+
+	if mod.muted[strings.ToLower(userId)] || mod.muted[strings.ToLower(user)] {
+		mod.record(direction, userId, user, content, "", "muted")
+		return "", false
+	}
+
+	result = content
+	for _, rule := range mod.config.Rules {
+		if rule.Direction != "" && rule.Direction != direction {
+			continue
+		}
+
+		if !rule.pattern.MatchString(result) {
+			continue
+		}
+
+		if rule.Action == ActionDrop {
+			mod.record(direction, userId, user, content, "", "blocklist: "+rule.pattern.String())
+			return "", false
+		}
+
+		result = rule.pattern.ReplaceAllString(result, rule.Replacement)
+	}
+
+	if reason := mod.spam(direction+":"+strings.ToLower(user), result, now); reason != "" {
+		mod.record(direction, userId, user, content, "", reason)
+		return "", false
+	}
+
+	if result != content {
+		mod.record(direction, userId, user, content, result, "blocklist")
+	}
+
+	return result, true
+}
+
+// spam returns why the message is spam, empty when it is not
+func (mod *moderator) spam(user, content string, now time.Time) (reason string) {
+	spam := mod.config.Spam
+	if spam == nil {
+		return ""
+	}
+
+	var history []*sent
+	for _, s := range mod.history[user] {
+		if now.Sub(s.at) < spam.Window {
+			history = append(history, s)
+		}
+	}
+
+	repeats := 0
+	for _, s := range history {
+		if strings.EqualFold(s.content, content) {
+			repeats++
+		}
+	}
+
+	mod.history[user] = append(history, &sent{at: now, content: content})
+
+	switch {
+	case spam.MaxRepeats > 0 && repeats >= spam.MaxRepeats:
+		return "spam: repeated message"
+	case spam.MaxMessages > 0 && len(history) >= spam.MaxMessages:
+		return "spam: too many messages"
+	}
+	return ""
+}
+
+func (mod *moderator) record(direction, userId, user, content, result, reason string) {
+	log.Infof("MODERATION %v %v(%v) %q => %q: %v", direction, user, userId, content, result, reason)
+
+	if mod.config.Log == "" {
+		return
+	}
+
+	b, err := json.Marshal(&moderationEntry{
+		Time:      time.Now(),
+		Direction: direction,
+		UserId:    userId,
+		User:      user,
+		Content:   content,
+		Result:    result,
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Errorf("json.Marshal failure: %v", err)
+		return
+	}
+
+	f, err := os.OpenFile(mod.config.Log, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Errorf("os.OpenFile(%v) failure: %v", mod.config.Log, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		log.Errorf("moderation log write failure: %v", err)
+	}
+}
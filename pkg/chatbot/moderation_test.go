@@ -0,0 +1,144 @@
+package chatbot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newModerator(t *testing.T, config *Moderation) *moderator {
+	cb := new(ChatBot)
+	if err := cb.SetModeration(config); err != nil {
+		t.Fatal(err)
+	}
+	return cb.moderator
+}
+
+func TestSetModeration(t *testing.T) {
+	for _, rule := range []*ModerationRule{
+		{},
+		{Words: []string{"bad"}, Regex: "worse"},
+		{Regex: "("},
+		{Words: []string{"bad"}, Action: "ban"},
+		{Words: []string{"bad"}, Direction: "both"},
+	} {
+		err := new(ChatBot).SetModeration(&Moderation{Rules: []*ModerationRule{rule}})
+		assert.NotNil(t, err, "%+v", rule)
+	}
+}
+
+func TestFilterRules(t *testing.T) {
+	mod := newModerator(t, &Moderation{Rules: []*ModerationRule{
+		{Words: []string{"bad", "c++"}},
+		{Regex: `(?i)h[a4]ck`, Replacement: "###", Direction: ToBridge},
+		{Words: []string{"drop"}, Action: ActionDrop, Direction: ToGame},
+	}})
+
+	for _, tc := range []struct {
+		direction string
+		content   string
+		result    string
+		ok        bool
+	}{
+		{ToGame, "hello", "hello", true},
+		{ToGame, "a BAD word", "a *** word", true},
+		{ToGame, "badge is not bad", "badge is not ***", true},
+		{ToGame, "I like c++", "I like ***", true},
+		{ToGame, "I like c", "I like c", true},
+		{ToGame, "h4ck it", "h4ck it", true},
+		{ToBridge, "h4ck it, HACK it", "### it, ### it", true},
+		{ToBridge, "bad hack", "*** ###", true},
+		{ToGame, "drop it", "", false},
+		{ToGame, "a bad drop", "", false},
+		{ToBridge, "drop it", "drop it", true},
+	} {
+		result, ok := mod.filter(tc.direction, "", "", "user", tc.content)
+		assert.Equal(t, tc.ok, ok, "%v %q", tc.direction, tc.content)
+		assert.Equal(t, tc.result, result, "%v %q", tc.direction, tc.content)
+	}
+}
+
+func TestFilterMuted(t *testing.T) {
+	mod := newModerator(t, &Moderation{Muted: []string{"123456789012345678", "Survivor Name"}})
+
+	for _, tc := range []struct {
+		userId string
+		user   string
+		ok     bool
+	}{
+		{"123456789012345678", "someone", false},
+		{"", "survivor name", false},
+		{"", "SURVIVOR NAME", false},
+		{"1", "Survivor", true},
+		{"", "someone", true},
+	} {
+		_, ok := mod.filter(ToGame, "", tc.userId, tc.user, "hello")
+		assert.Equal(t, tc.ok, ok, "%q %q", tc.userId, tc.user)
+	}
+}
+
+func TestSpamRepeats(t *testing.T) {
+	mod := newModerator(t, &Moderation{Spam: &Spam{Window: time.Minute, MaxRepeats: 2}})
+	now := time.Now()
+
+	assert.Equal(t, "", mod.spam("user", "hello", now))
+	assert.Equal(t, "", mod.spam("user", "HELLO", now.Add(time.Second)))
+	assert.NotEqual(t, "", mod.spam("user", "hello", now.Add(2*time.Second)))
+	assert.Equal(t, "", mod.spam("user", "other", now.Add(3*time.Second)))
+	assert.Equal(t, "", mod.spam("another", "hello", now.Add(3*time.Second)))
+
+	// the first two left the window, the dropped one is still in it
+	assert.Equal(t, "", mod.spam("user", "hello", now.Add(time.Minute+time.Second)))
+	assert.NotEqual(t, "", mod.spam("user", "hello", now.Add(time.Minute+time.Second+time.Millisecond)))
+}
+
+func TestSpamMessages(t *testing.T) {
+	mod := newModerator(t, &Moderation{Spam: &Spam{MaxMessages: 3}})
+	assert.Equal(t, time.Minute, mod.config.Spam.Window)
+	now := time.Now()
+
+	for i, content := range []string{"a", "b", "c"} {
+		assert.Equal(t, "", mod.spam("user", content, now.Add(time.Duration(i)*time.Second)), content)
+	}
+	assert.NotEqual(t, "", mod.spam("user", "d", now.Add(3*time.Second)))
+	assert.Equal(t, "", mod.spam("another", "a", now.Add(3*time.Second)))
+
+	// a and b left the window, c and d are in it
+	assert.Equal(t, "", mod.spam("user", "e", now.Add(time.Minute+time.Second+time.Millisecond)))
+	assert.NotEqual(t, "", mod.spam("user", "f", now.Add(time.Minute+time.Second+2*time.Millisecond)))
+}
+
+func TestFilterVerdicts(t *testing.T) {
+	mod := newModerator(t, &Moderation{
+		Rules: []*ModerationRule{{Words: []string{"bad"}}},
+		Spam:  &Spam{Window: time.Minute, MaxRepeats: 1},
+	})
+
+	// every route of the cluster sees the in-game message, it is one message
+	for route := 0; route < 3; route++ {
+		result, ok := mod.filter(ToBridge, "hash1", "", "user", "a bad word")
+		assert.True(t, ok, "route %v", route)
+		assert.Equal(t, "a *** word", result, "route %v", route)
+	}
+
+	// the same content again is another message and a repeat
+	for route := 0; route < 3; route++ {
+		result, ok := mod.filter(ToBridge, "hash2", "", "user", "a bad word")
+		assert.False(t, ok, "route %v", route)
+		assert.Equal(t, "", result, "route %v", route)
+	}
+
+	// messages without a key are judged each time
+	_, ok := mod.filter(ToGame, "", "", "user", "hello")
+	assert.True(t, ok)
+	_, ok = mod.filter(ToGame, "", "", "user", "hello")
+	assert.False(t, ok)
+}
+
+func TestFilterNil(t *testing.T) {
+	var mod *moderator
+	result, ok := mod.filter(ToGame, "", "", "user", "a bad word")
+	assert.True(t, ok)
+	assert.Equal(t, "a bad word", result)
+}
@@ -0,0 +1,150 @@
+package chatbot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+)
+
+const (
+	// outboxMax bounds the messages queued per route while the bridge is
+	// unreachable, the oldest are dropped first
+	outboxMax = 1000
+	// sendAttempts is the number of tries of one platform message
+	sendAttempts = 5
+)
+
+// OutboxStats counts the in-game messages forwarded on a route
+type OutboxStats struct {
+	Sent    uint64 `json:"sent"`
+	Failed  uint64 `json:"failed"`
+	Dropped uint64 `json:"dropped"`
+	Queued  int    `json:"queued"`
+}
+
+// outbox queues the in-game messages of a route. Messages arriving within
+// the batch window are coalesced into as few platform messages as possible.
+type outbox struct {
+	route *Route
+
+	mu      sync.Mutex
+	pending []*RedisMessage
+	notify  chan struct{}
+
+	sent    atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+func newOutbox(route *Route) *outbox {
+	return &outbox{
+		route:  route,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (box *outbox) push(msg *RedisMessage) {
+	box.mu.Lock()
+	if len(box.pending) >= outboxMax {
+		box.pending = box.pending[1:]
+		if n := box.dropped.Add(1); n == 1 || n%100 == 0 {
+			log.Warnf("[%v] outbox is full, %v messages dropped", box.route, n)
+		}
+	}
+	box.pending = append(box.pending, msg)
+	box.mu.Unlock()
+
+	select {
+	case box.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (box *outbox) take() (msgs []*RedisMessage) {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+
+	msgs = box.pending
+	box.pending = nil
+	return msgs
+}
+
+// requeue puts back the messages not sent, ahead of the new ones
+func (box *outbox) requeue(msgs []*RedisMessage) {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+
+	box.pending = append(msgs, box.pending...)
+	if over := len(box.pending) - outboxMax; over > 0 {
+		box.pending = box.pending[over:]
+		box.dropped.Add(uint64(over))
+	}
+}
+
+func (box *outbox) stats() *OutboxStats {
+	box.mu.Lock()
+	queued := len(box.pending)
+	box.mu.Unlock()
+
+	return &OutboxStats{
+		Sent:    box.sent.Load(),
+		Failed:  box.failed.Load(),
+		Dropped: box.dropped.Load(),
+		Queued:  queued,
+	}
+}
+
+// Stats returns the outbox counters of each route
+func (cb *ChatBot) Stats() map[string]*OutboxStats {
+	stats := map[string]*OutboxStats{}
+	for route, box := range cb.outboxes {
+		stats[route.String()] = box.stats()
+	}
+	return stats
+}
+
+// Forward queues an in-game message to the channel of the route
+func (cb *ChatBot) Forward(route *Route, msg *RedisMessage) {
+	cb.outboxes[route].push(msg)
+}
+
+// runOutbox sends the queued messages of the route until ctx is done
+func (cb *ChatBot) runOutbox(ctx context.Context, route *Route, bridge Bridge) {
+	box := cb.outboxes[route]
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-box.notify:
+		}
+
+		// let the burst settle
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(route.BatchWindow):
+		}
+
+		msgs := box.take()
+		for len(msgs) > 0 {
+			n, err := bridge.Send(ctx, route, msgs)
+			if err != nil {
+				if ctx.Err() != nil {
+					box.requeue(msgs)
+					return
+				}
+
+				failed := box.failed.Add(uint64(n))
+				log.Errorf("[%v] %v send failure, %v messages lost (%v in total): %v", route, bridge.Name(), n, failed, err)
+			} else {
+				box.sent.Add(uint64(n))
+			}
+
+			msgs = msgs[n:]
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// listCheckInterval is how often a push delivery compares the cluster chat
+// list with the messages it was pushed
+const listCheckInterval = 30 * time.Second
+
+// seenMax bounds the hashes a push delivery remembers, far more than the
+// list holds
+const seenMax = 1024
+
+// seenSet is the hashes of the messages a push delivery forwarded, the
+// oldest are forgotten first
+type seenSet struct {
+	hashes map[string]bool
+	order  []string
+}
+
+func newSeenSet() *seenSet {
+	return &seenSet{hashes: map[string]bool{}}
+}
+
+func (seen *seenSet) has(hash string) bool {
+	return seen.hashes[hash]
+}
+
+func (seen *seenSet) add(hash string) {
+	if seen.hashes[hash] {
+		return
+	}
+
+	seen.hashes[hash] = true
+	seen.order = append(seen.order, hash)
+	if len(seen.order) > seenMax {
+		delete(seen.hashes, seen.order[0])
+		seen.order = seen.order[1:]
+	}
+}
+
+// pushDelivery forwards the messages pushed to a route, DeliverySubscribe or
+// DeliveryQueue, each once. Producers that only LPUSH the cluster chat list
+// are not silently lost: a message of the list that was not pushed by the
+// next list check is forwarded from the list, with a warning.
+type pushDelivery struct {
+	cb    *ChatBot
+	route *Route
+	// source is the channel or list the messages are pushed on
+	source string
+
+	mu   sync.Mutex
+	seen *seenSet
+	// unpushed are the messages of the list at the last check that were
+	// not pushed yet
+	unpushed map[string]bool
+}
+
+// Subscribe forwards each message published on the chat channel once, as it
+// arrives. Messages still in the list since the last cursor are caught up
+// first.
+func (cb *ChatBot) Subscribe(ctx context.Context, route *Route) (err error) {
+	push := &pushDelivery{cb: cb, route: route, source: route.ChatChannel}
+	return push.run(ctx, cb.transport.Subscribe)
+}
+
+// Queue forwards each message the producers push on the queue list of the
+// route, taking it off the list. Messages pushed while the bot is down wait
+// in the list.
+func (cb *ChatBot) Queue(ctx context.Context, route *Route) (err error) {
+	push := &pushDelivery{cb: cb, route: route, source: route.Queue}
+	return push.run(ctx, cb.transport.Pop)
+}
+
+func (push *pushDelivery) run(ctx context.Context, receive func(ctx context.Context, key string, fn func(value string)) error) (err error) {
+	cb, route := push.cb, push.route
+
+	msgs, err := cb.LRange(ctx, route, 0, 19)
+	if err != nil {
+		return errors.Wrap(err, "cb.LRange")
+	}
+	if cb.cursors.get(route.String()) != nil {
+		cb.forwardAfterCursor(route, msgs)
+	}
+
+	// the list is the baseline, it was caught up or is older than the bot
+	push.seen = newSeenSet()
+	for _, msg := range msgs {
+		push.seen.add(msg.hash)
+	}
+
+	log.Infof("[%v] %v %v", route, route.Delivery, push.source)
+
+	cb.health.subscribed(route, true)
+	defer cb.health.subscribed(route, false)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(listCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := push.check(ctx); err != nil && ctx.Err() == nil {
+				log.Warnf("[%v] list check failure: %v", route, err)
+			}
+		}
+	}()
+
+	if err := receive(ctx, push.source, func(value string) {
+		var msg *RedisMessage
+		if err := json.Unmarshal([]byte(value), &msg); err != nil {
+			log.Errorf("json.Unmarshal failure: %v", err)
+			return
+		}
+		msg.hash = hashMessage(value)
+
+		push.mu.Lock()
+		defer push.mu.Unlock()
+
+		if push.forward(msg) {
+			cb.setCursor(route, msg)
+		}
+	}); err != nil {
+		return errors.Wrapf(err, "%v(%v)", route.Delivery, push.source)
+	}
+
+	return nil
+}
+
+// forward forwards msg unless it already was, push.mu is held
+func (push *pushDelivery) forward(msg *RedisMessage) bool {
+	if push.seen.has(msg.hash) {
+		return false
+	}
+	push.seen.add(msg.hash)
+
+	push.cb.recordGame(push.route, msg)
+	push.cb.relay(push.route, msg)
+	return true
+}
+
+// check forwards the messages of the cluster chat list that were not pushed
+// since the previous check
+func (push *pushDelivery) check(ctx context.Context) (err error) {
+	cb, route := push.cb, push.route
+
+	msgs, err := cb.LRange(ctx, route, 0, 19)
+	if err != nil {
+		return errors.Wrap(err, "cb.LRange")
+	}
+
+	push.mu.Lock()
+	defer push.mu.Unlock()
+
+	var missed []*RedisMessage
+	unpushed := map[string]bool{}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		msg := msgs[i]
+		switch {
+		case push.seen.has(msg.hash):
+		case push.unpushed[msg.hash]:
+			missed = append(missed, msg)
+		default:
+			unpushed[msg.hash] = true
+		}
+	}
+	push.unpushed = unpushed
+
+	if len(missed) > 0 {
+		log.Warnf("[%v] %v messages of the list %v were not pushed on %v, forwarded from the list: every producer must push each message on %v",
+			route, len(missed), route.Cluster, push.source, push.source)
+	}
+	for _, msg := range missed {
+		push.forward(msg)
+	}
+
+	// the cursor moves to the newest message all older ones of which were
+	// forwarded, never back
+	newest := len(msgs)
+	for newest > 0 && push.seen.has(msgs[newest-1].hash) {
+		newest--
+	}
+	if newest == len(msgs) {
+		return nil
+	}
+
+	pos := len(msgs)
+	if cursor := cb.cursors.get(route.String()); cursor != nil {
+		for i, msg := range msgs {
+			if msg.hash == cursor.Hash {
+				pos = i
+				break
+			}
+		}
+	}
+	if newest < pos {
+		cb.setCursor(route, msgs[newest])
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+package chatbot
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RedisError is an error reply from the redis server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// redisTimeout bounds dialing and a command whose ctx has no deadline, a
+// stalled server never holds the shared connection for longer
+var redisTimeout = 10 * time.Second
+
+// Redis is a Transport speaking RESP to the redis server directly
+type Redis struct {
+	addr      string
+	username  string
+	password  string
+	db        int
+	tlsConfig *tls.Config
+
+	mu   sync.Mutex
+	conn *redisConn
+}
+
+func NewRedis(addr, username, password string, db int, tlsConfig *tls.Config) *Redis {
+	return &Redis{
+		addr:      addr,
+		username:  username,
+		password:  password,
+		db:        db,
+		tlsConfig: tlsConfig,
+	}
+}
+
+// Do sends a command on the shared connection, reconnecting when the
+// previous connection failed
+func (r *Redis) Do(ctx context.Context, args ...string) (reply any, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		r.conn, err = r.dial(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "redis.dial")
+		}
+	}
+
+	reply, err = r.conn.do(ctx, args...)
+	if err != nil {
+		if _, ok := err.(RedisError); !ok {
+			r.conn.Close()
+			r.conn = nil
+		}
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+func (r *Redis) dial(ctx context.Context) (conn *redisConn, err error) {
+	var nc net.Conn
+	dialer := &net.Dialer{Timeout: redisTimeout, KeepAlive: 30 * time.Second}
+
+	if r.tlsConfig != nil {
+		config := r.tlsConfig.Clone()
+		if config.ServerName == "" {
+			if host, _, err := net.SplitHostPort(r.addr); err == nil {
+				config.ServerName = host
+			}
+		}
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: config}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "dial(%v)", r.addr)
+	}
+
+	conn = &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	if err := r.setup(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// setup authenticates and selects the db of a new connection
+func (r *Redis) setup(ctx context.Context, conn *redisConn) (err error) {
+	if r.password != "" {
+		args := []string{"AUTH", r.password}
+		if r.username != "" {
+			args = []string{"AUTH", r.username, r.password}
+		}
+		if _, err := conn.do(ctx, args...); err != nil {
+			return errors.Wrap(err, "AUTH")
+		}
+	}
+
+	if r.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(r.db)); err != nil {
+			return errors.Wrapf(err, "SELECT %v", r.db)
+		}
+	}
+
+	return nil
+}
+
+func (r *Redis) LPush(ctx context.Context, key, value string) (err error) {
+	if _, err := r.Do(ctx, "LPUSH", key, value); err != nil {
+		return errors.Wrap(err, "LPUSH")
+	}
+	return nil
+}
+
+func (r *Redis) LTrim(ctx context.Context, key string, start, end int) (err error) {
+	if _, err := r.Do(ctx, "LTRIM", key, strconv.Itoa(start), strconv.Itoa(end)); err != nil {
+		return errors.Wrap(err, "LTRIM")
+	}
+	return nil
+}
+
+func (r *Redis) LRange(ctx context.Context, key string, start, end int) (items []string, err error) {
+	reply, err := r.Do(ctx, "LRANGE", key, strconv.Itoa(start), strconv.Itoa(end))
+	if err != nil {
+		return nil, errors.Wrap(err, "LRANGE")
+	}
+
+	arr, ok := reply.([]any)
+	if !ok {
+		return nil, errors.Errorf("LRANGE failure: unexpected reply %v", reply)
+	}
+
+	for _, item := range arr {
+		s, ok := item.(string)
+		if !ok {
+			return nil, errors.Errorf("LRANGE failure: unexpected item %v", item)
+		}
+		items = append(items, s)
+	}
+
+	return items, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, channel, value string) (err error) {
+	if _, err := r.Do(ctx, "PUBLISH", channel, value); err != nil {
+		return errors.Wrap(err, "PUBLISH")
+	}
+	return nil
+}
+
+func (r *Redis) Subscribe(ctx context.Context, channel string, fn func(value string)) (err error) {
+	// a subscribed connection can not run other commands
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return errors.Wrap(err, "redis.dial")
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	// a subscribed connection waits for messages as long as ctx lasts
+	conn.SetDeadline(time.Time{})
+	if err := conn.send("SUBSCRIBE", channel); err != nil {
+		return errors.Wrap(err, "SUBSCRIBE")
+	}
+
+	for {
+		reply, err := conn.receive()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Wrap(err, "SUBSCRIBE")
+		}
+
+		// ["subscribe", channel, count] or ["message", channel, payload]
+		arr, ok := reply.([]any)
+		if !ok || len(arr) != 3 {
+			return errors.Errorf("SUBSCRIBE failure: unexpected reply %v", reply)
+		}
+
+		if kind, _ := arr[0].(string); kind != "message" {
+			continue
+		}
+
+		if payload, ok := arr[2].(string); ok {
+			fn(payload)
+		}
+	}
+}
+
+// popTimeout is how long one BRPOP blocks, a dead connection is noticed
+// after popTimeout and the read margin
+const popTimeout = 5 * time.Second
+
+func (r *Redis) Pop(ctx context.Context, key string, fn func(value string)) (err error) {
+	// BRPOP blocks its connection, the shared one keeps serving LPUSH
+	conn, err := r.dial(ctx)
+	if err != nil {
+		return errors.Wrap(err, "redis.dial")
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	timeout := strconv.Itoa(int(popTimeout / time.Second))
+	for {
+		popCtx, cancel := context.WithTimeout(ctx, popTimeout+10*time.Second)
+		reply, err := conn.do(popCtx, "BRPOP", key, timeout)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Wrap(err, "BRPOP")
+		}
+
+		// nil on timeout or [key, value]
+		if reply == nil {
+			continue
+		}
+		arr, ok := reply.([]any)
+		if !ok || len(arr) != 2 {
+			return errors.Errorf("BRPOP failure: unexpected reply %v", reply)
+		}
+
+		if value, ok := arr[1].(string); ok {
+			fn(value)
+		}
+	}
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (conn *redisConn) do(ctx context.Context, args ...string) (reply any, err error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	if err := conn.send(args...); err != nil {
+		return nil, err
+	}
+
+	return conn.receive()
+}
+
+func (conn *redisConn) send(args ...string) (err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%v\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%v\r\n%v\r\n", len(arg), arg)
+	}
+
+	if _, err := io.WriteString(conn, b.String()); err != nil {
+		return errors.Wrap(err, "redis write")
+	}
+	return nil
+}
+
+// receive reads one reply: string, int64, []any, nil or RedisError
+func (conn *redisConn) receive() (reply any, err error) {
+	line, err := conn.r.ReadString('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "redis read")
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.Errorf("redis protocol error: empty line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "redis protocol error: integer %q", line)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "redis protocol error: bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(conn.r, data); err != nil {
+			return nil, errors.Wrap(err, "redis read")
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "redis protocol error: array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		arr := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := conn.receive()
+			if err != nil {
+				if _, ok := err.(RedisError); !ok {
+					return nil, err
+				}
+				item = err
+			}
+			arr = append(arr, item)
+		}
+		return arr, nil
+	default:
+		return nil, errors.Errorf("redis protocol error: unexpected %q", line)
+	}
+}
@@ -0,0 +1,199 @@
+package chatbot
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis is the server end of a net.Pipe, it records the commands and
+// answers each with the next of replies
+func fakeRedis(t *testing.T, replies ...string) (conn *redisConn, commands chan []any) {
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	commands = make(chan []any, 16)
+	go func() {
+		defer close(commands)
+
+		srv := &redisConn{Conn: server, r: bufio.NewReader(server)}
+		for _, reply := range replies {
+			command, err := srv.receive()
+			if err != nil {
+				return
+			}
+			commands <- command.([]any)
+
+			if _, err := server.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}()
+
+	return &redisConn{Conn: client, r: bufio.NewReader(client)}, commands
+}
+
+func TestRedisSend(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := &redisConn{Conn: client}
+	go conn.send("LPUSH", "chat", "a b\r\n", "")
+
+	expected := "*4\r\n$5\r\nLPUSH\r\n$4\r\nchat\r\n$5\r\na b\r\n\r\n$0\r\n\r\n"
+	buf := make([]byte, len(expected))
+	_, err := io.ReadFull(server, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(buf))
+}
+
+func TestRedisReceive(t *testing.T) {
+	for _, tc := range []struct {
+		raw   string
+		reply any
+		err   string
+	}{
+		{raw: "+OK\r\n", reply: "OK"},
+		{raw: "-ERR unknown command\r\n", err: "ERR unknown command"},
+		{raw: ":42\r\n", reply: int64(42)},
+		{raw: ":-1\r\n", reply: int64(-1)},
+		{raw: "$5\r\nhello\r\n", reply: "hello"},
+		{raw: "$0\r\n\r\n", reply: ""},
+		{raw: "$4\r\na\r\nb\r\n", reply: "a\r\nb"},
+		{raw: "$-1\r\n", reply: nil},
+		{raw: "*0\r\n", reply: []any{}},
+		{raw: "*-1\r\n", reply: nil},
+		{raw: "*3\r\n$4\r\nchat\r\n:1\r\n$-1\r\n", reply: []any{"chat", int64(1), nil}},
+		{raw: "*2\r\n*1\r\n+a\r\n-ERR b\r\n", reply: []any{[]any{"a"}, RedisError("ERR b")}},
+		{raw: "\r\n", err: "empty line"},
+		{raw: "?what\r\n", err: "unexpected"},
+		{raw: ":x\r\n", err: "integer"},
+		{raw: "$x\r\n", err: "bulk length"},
+		{raw: "*x\r\n", err: "array length"},
+		{raw: "$5\r\nhel", err: "redis read"},
+		{raw: "*2\r\n+a\r\n", err: "redis read"},
+		{raw: "", err: "redis read"},
+	} {
+		conn := &redisConn{r: bufio.NewReader(strings.NewReader(tc.raw))}
+		reply, err := conn.receive()
+		if tc.err != "" {
+			if assert.NotNil(t, err, "%q", tc.raw) {
+				assert.Contains(t, err.Error(), tc.err, "%q", tc.raw)
+			}
+			continue
+		}
+		assert.Nil(t, err, "%q", tc.raw)
+		assert.Equal(t, tc.reply, reply, "%q", tc.raw)
+	}
+
+	// an error reply is a RedisError, the connection stays usable
+	conn := &redisConn{r: bufio.NewReader(strings.NewReader("-WRONGTYPE x\r\n+OK\r\n"))}
+	_, err := conn.receive()
+	assert.Equal(t, RedisError("WRONGTYPE x"), err)
+	reply, err := conn.receive()
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", reply)
+}
+
+func TestRedisSetup(t *testing.T) {
+	for _, tc := range []struct {
+		redis    *Redis
+		replies  []string
+		commands [][]any
+		err      string
+	}{
+		{redis: &Redis{}},
+		{
+			redis:    &Redis{password: "secret"},
+			replies:  []string{"+OK\r\n"},
+			commands: [][]any{{"AUTH", "secret"}},
+		},
+		{
+			redis:    &Redis{username: "bot", password: "secret", db: 2},
+			replies:  []string{"+OK\r\n", "+OK\r\n"},
+			commands: [][]any{{"AUTH", "bot", "secret"}, {"SELECT", "2"}},
+		},
+		{
+			redis:    &Redis{password: "wrong", db: 2},
+			replies:  []string{"-WRONGPASS invalid username-password pair\r\n"},
+			commands: [][]any{{"AUTH", "wrong"}},
+			err:      "AUTH: WRONGPASS",
+		},
+		{
+			redis:    &Redis{db: 99},
+			replies:  []string{"-ERR DB index is out of range\r\n"},
+			commands: [][]any{{"SELECT", "99"}},
+			err:      "SELECT 99: ERR DB index",
+		},
+	} {
+		conn, commands := fakeRedis(t, tc.replies...)
+
+		err := tc.redis.setup(context.Background(), conn)
+		if tc.err != "" {
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		} else {
+			assert.Nil(t, err)
+		}
+
+		conn.Close()
+		var got [][]any
+		for command := range commands {
+			got = append(got, command)
+		}
+		assert.Equal(t, tc.commands, got)
+	}
+}
+
+func TestRedisDo(t *testing.T) {
+	conn, commands := fakeRedis(t, "*2\r\n$1\r\na\r\n$1\r\nb\r\n")
+
+	reply, err := conn.do(context.Background(), "LRANGE", "chat", "0", "19")
+	assert.Nil(t, err)
+	assert.Equal(t, []any{"a", "b"}, reply)
+	assert.Equal(t, []any{"LRANGE", "chat", "0", "19"}, <-commands)
+}
+
+func TestRedisTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { redisTimeout = timeout }(redisTimeout)
+	redisTimeout = 50 * time.Millisecond
+
+	// the server reads the command and never answers
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := server.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn := &redisConn{Conn: client, r: bufio.NewReader(client)}
+
+	started := time.Now()
+	_, err := conn.do(context.Background(), "PING")
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	// a deadline of ctx wins over the default
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	started = time.Now()
+	_, err = conn.do(ctx, "PING")
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(started), redisTimeout)
+}
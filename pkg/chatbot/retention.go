@@ -0,0 +1,170 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// discord refuses to bulk delete messages older than 14 days
+const bulkDeleteMaxAge = 14 * 24 * time.Hour
+
+type Retention struct {
+	// Age is how long messages are kept, retention is disabled when 0
+	Age time.Duration `mapstructure:"age"`
+	// Interval is how often the channel is checked
+	Interval time.Duration `mapstructure:"interval"`
+	// KeepPinned keeps pinned messages
+	KeepPinned bool `mapstructure:"keep-pinned"`
+	// OnlyBot deletes only the messages of the bot
+	OnlyBot bool `mapstructure:"only-bot"`
+	// Archive appends the deleted messages to this file as json lines
+	Archive string `mapstructure:"archive"`
+}
+
+type archivedMessage struct {
+	Id          string    `json:"id"`
+	ChannelId   string    `json:"channel_id"`
+	Timestamp   time.Time `json:"timestamp"`
+	AuthorId    string    `json:"author_id"`
+	Author      string    `json:"author"`
+	Content     string    `json:"content"`
+	Attachments []string  `json:"attachments,omitempty"`
+}
+
+func (d *Discord) applyRetention(route *Route) {
+	retention := route.Retention
+	channelId := route.ChannelId
+
+	log.Infof("check delete message on %v", channelId)
+
+	now := time.Now()
+	pivot := now.Add(-retention.Age)
+
+	var bulkIds, singleIds []string
+	var archived []*discordgo.Message
+
+	for msg := range d.getAllMessages(channelId) {
+		if !pivot.After(msg.Timestamp) {
+			continue
+		}
+
+		if retention.KeepPinned && msg.Pinned {
+			continue
+		}
+
+		if retention.OnlyBot && !d.isOwnMessage(msg) {
+			continue
+		}
+
+		log.Infof("delete message: %v %v", msg.Timestamp, msg.Content)
+		archived = append(archived, msg)
+
+		if now.Sub(msg.Timestamp) < bulkDeleteMaxAge-time.Hour {
+			bulkIds = append(bulkIds, msg.ID)
+		} else {
+			singleIds = append(singleIds, msg.ID)
+		}
+	}
+
+	if retention.Archive != "" && len(archived) > 0 {
+		if err := archiveMessages(retention.Archive, archived); err != nil {
+			// keep the messages rather than lose them
+			log.Errorf("archiveMessages(%v) failure: %v", retention.Archive, err)
+			return
+		}
+	}
+
+	for len(bulkIds) > 0 {
+		var ids []string
+		if len(bulkIds) > 100 {
+			ids = bulkIds[0:100]
+			bulkIds = bulkIds[100:]
+		} else {
+			ids = bulkIds
+			bulkIds = nil
+		}
+
+		if err := d.dg.ChannelMessagesBulkDelete(channelId, ids); err != nil {
+			log.Errorf("dg.ChannelMessagesBulkDelete failure: %v", err)
+		}
+	}
+
+	for _, id := range singleIds {
+		if err := d.dg.ChannelMessageDelete(channelId, id); err != nil {
+			log.Errorf("dg.ChannelMessageDelete(%v) failure: %v", id, err)
+		}
+	}
+}
+
+func (d *Discord) isOwnMessage(msg *discordgo.Message) bool {
+	if msg.WebhookID != "" {
+		return d.webhooks.owns(msg.WebhookID)
+	}
+	return msg.Author != nil && msg.Author.ID == d.dg.State.User.ID
+}
+
+func archiveMessages(path string, msgs []*discordgo.Message) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "os.OpenFile(%v)", path)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, msg := range msgs {
+		item := &archivedMessage{
+			Id:        msg.ID,
+			ChannelId: msg.ChannelID,
+			Timestamp: msg.Timestamp,
+			Content:   msg.Content,
+		}
+		if msg.Author != nil {
+			item.AuthorId = msg.Author.ID
+			item.Author = msg.Author.String()
+		}
+		for _, attachment := range msg.Attachments {
+			item.Attachments = append(item.Attachments, attachment.URL)
+		}
+
+		if err := enc.Encode(item); err != nil {
+			return errors.Wrap(err, "json.Encode")
+		}
+	}
+
+	return f.Sync()
+}
+
+func (d *Discord) getAllMessages(channelId string) <-chan *discordgo.Message {
+
+	ch := make(chan *discordgo.Message)
+	go func() {
+		defer close(ch)
+
+		var lastId string
+
+		for {
+			msgs, err := d.dg.ChannelMessages(channelId, 100, lastId, "", "")
+			if err != nil {
+				log.Errorf("dg.ChannelMessages failure: %v", err)
+				return
+			}
+
+			if len(msgs) == 0 {
+				return
+			}
+
+			for _, msg := range msgs {
+				ch <- msg
+			}
+			lastId = msgs[len(msgs)-1].ID
+		}
+
+	}()
+
+	return ch
+}
@@ -0,0 +1,151 @@
+package chatbot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultFormat = "```md\n[%v][%v][%v]: %v\n```"
+
+// Route bridges the chat of one cluster and one discord channel
+type Route struct {
+	// Cluster is the cluster id, the key of the cluster chat list
+	Cluster string `mapstructure:"cluster"`
+	// ServerName forwards only the messages of this server (map) when set
+	ServerName string `mapstructure:"server-name"`
+	// Bridge is the name of the chat platform, DiscordBridge by default
+	Bridge string `mapstructure:"bridge"`
+	// ChannelId is the channel of the bridge: the discord channel id, the irc
+	// channel or the http target
+	ChannelId string `mapstructure:"channel-id"`
+	// Format is the discord display format of [server][tribe][survivor]: message
+	Format string `mapstructure:"format"`
+	// Style is StyleText or StyleEmbed
+	Style string `mapstructure:"style"`
+	// Webhook sends messages through a channel webhook as "[Tribe] Survivor (Map)"
+	Webhook bool `mapstructure:"webhook"`
+	// Avatar is the avatar url of the webhook messages
+	Avatar string `mapstructure:"avatar"`
+	// Avatars overrides Avatar per server name
+	Avatars map[string]string `mapstructure:"avatars"`
+	// BatchWindow coalesces the messages of a burst into one discord message
+	BatchWindow time.Duration `mapstructure:"batch-window"`
+
+	// Delivery is DeliveryPoll, DeliverySubscribe or DeliveryQueue
+	Delivery string `mapstructure:"delivery"`
+	// ChatChannel is the pub/sub channel of DeliverySubscribe
+	ChatChannel string `mapstructure:"chat-channel"`
+	// Queue is the list of DeliveryQueue, of this route only. The game
+	// servers must LPUSH every message on it as well as on the cluster list.
+	Queue string `mapstructure:"queue"`
+
+	// Retention deletes old messages of the discord channel
+	Retention *Retention `mapstructure:"retention"`
+
+	// Events posts player and tribe events, disabled when nil
+	Events *Events `mapstructure:"events"`
+}
+
+func (route *Route) String() string {
+	src := route.Cluster
+	if route.ServerName != "" {
+		src += "/" + route.ServerName
+	}
+
+	dst := route.ChannelId
+	if route.Bridge != "" && !strings.EqualFold(route.Bridge, DiscordBridge) {
+		dst = route.Bridge + ":" + dst
+	}
+
+	return src + "=>" + dst
+}
+
+func (route *Route) setDefaults() {
+	if route.Bridge == "" {
+		route.Bridge = DiscordBridge
+	}
+
+	if route.Format == "" {
+		route.Format = DefaultFormat
+	}
+
+	if route.Style == "" {
+		route.Style = StyleText
+	}
+
+	if route.BatchWindow <= 0 {
+		route.BatchWindow = time.Second
+	}
+
+	if route.Delivery == "" {
+		route.Delivery = DeliveryPoll
+	}
+
+	if route.ChatChannel == "" {
+		route.ChatChannel = route.Cluster
+	}
+
+	if route.Retention == nil {
+		route.Retention = &Retention{}
+	}
+
+	if route.Retention.Interval <= 0 {
+		route.Retention.Interval = 3 * time.Hour
+	}
+}
+
+func (route *Route) validate() (err error) {
+	if route.Cluster == "" {
+		return errors.Errorf("route %v: cluster is empty", route)
+	}
+
+	if route.ChannelId == "" {
+		return errors.Errorf("route %v: channel-id is empty", route)
+	}
+
+	switch route.Style {
+	case StyleText, StyleEmbed:
+	default:
+		return errors.Errorf("route %v: unknown style: %v", route, route.Style)
+	}
+
+	if route.Events != nil {
+		switch route.Events.PlayerCount {
+		case "", PlayerCountTopic, PlayerCountPresence:
+		default:
+			return errors.Errorf("route %v: unknown player-count: %v", route, route.Events.PlayerCount)
+		}
+	}
+
+	switch route.Delivery {
+	case DeliveryPoll, DeliverySubscribe:
+	case DeliveryQueue:
+		if route.Queue == "" {
+			return errors.Errorf("route %v: queue delivery needs a queue list", route)
+		}
+		// popping the cluster list would take the chat from the game servers
+		if route.Queue == route.Cluster {
+			return errors.Errorf("route %v: the queue list can not be the cluster list", route)
+		}
+	default:
+		return errors.Errorf("route %v: unknown delivery mode: %v", route, route.Delivery)
+	}
+
+	return nil
+}
+
+// accept reports whether an in-game message is forwarded on this route.
+// Messages of other bridges are, the messages of its own bridge are not.
+func (route *Route) accept(msg *RedisMessage) bool {
+	if strings.EqualFold(msg.ServerName, route.Bridge) {
+		return false
+	}
+
+	if route.ServerName != "" && msg.ServerName != route.ServerName {
+		return false
+	}
+
+	return true
+}
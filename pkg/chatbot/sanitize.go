@@ -0,0 +1,227 @@
+package chatbot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// gameMessageMax is the number of characters the in-game chat shows of one
+// message, longer discord messages are split
+const gameMessageMax = 200
+
+var (
+	patternUserMention    = regexp.MustCompile(`<@!?(\d+)>`)
+	patternRoleMention    = regexp.MustCompile(`<@&(\d+)>`)
+	patternChannelMention = regexp.MustCompile(`<#(\d+)>`)
+	patternCustomEmoji    = regexp.MustCompile(`<a?:(\w+):\d+>`)
+	patternTimestamp      = regexp.MustCompile(`<t:(-?\d+)(?::[tTdDfFR])?>`)
+	patternLink           = regexp.MustCompile(`\[([^\]]+)\]\((<?)(https?://[^)>\s]+)>?\)`)
+	patternAngleLink      = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	patternEmphasis       = regexp.MustCompile(`(^|\W)[*_]([^*_\s][^*_]*?)[*_](\W|$)`)
+	patternHeading        = regexp.MustCompile(`(?m)^(#{1,3}|>{1,3}|-#) `)
+)
+
+// markdownReplacer removes the discord markdown which is never part of a text
+var markdownReplacer = strings.NewReplacer(
+	"```", "",
+	"**", "",
+	"__", "",
+	"~~", "",
+	"||", "",
+	"`", "",
+)
+
+// emojiNames are text forms of common emoji, the in-game font has no emoji
+var emojiNames = map[rune]string{
+	'😀': ":D", '😃': ":D", '😄': ":D", '😁': ":D", '😆': "XD", '😂': ":'D", '🤣': ":'D",
+	'🙂': ":)", '😊': ":)", '😉': ";)", '😍': "<3", '😘': ":*", '😛': ":P", '😜': ";P",
+	'😐': ":|", '😕': ":/", '🙁': ":(", '😞': ":(", '😢': ":'(", '😭': ":'(", '😡': ">:(",
+	'😮': ":O", '😱': ":O", '😎': "B)", '🤔': ":thinking:", '👍': ":+1:", '👎': ":-1:",
+	'👋': ":wave:", '🙏': ":pray:", '👏': ":clap:", '🔥': ":fire:", '💀': ":skull:",
+	'❤': "<3", '💔': "</3", '🎉': ":tada:", '✅': ":white_check_mark:", '❌': ":x:",
+	'🦖': ":t-rex:", '🦕': ":sauropod:",
+}
+
+// gameContent translates a discord message to the plain text of the in-game
+// chat: mentions become names, emoji become text, markdown is stripped and
+// attachments and stickers are replaced with their names
+func gameContent(s *discordgo.Session, m *discordgo.Message) string {
+	content, err := m.ContentWithMoreMentionsReplaced(s)
+	if err != nil {
+		content = m.ContentWithMentionsReplaced()
+	}
+
+	guildId := m.GuildID
+
+	// mentions left by discordgo: unknown users, not mentionable roles
+	content = patternUserMention.ReplaceAllString(content, "@user")
+	content = patternRoleMention.ReplaceAllStringFunc(content, func(mention string) string {
+		id := patternRoleMention.FindStringSubmatch(mention)[1]
+		if role, err := s.State.Role(guildId, id); err == nil {
+			return "@" + role.Name
+		}
+		return "@role"
+	})
+	content = patternChannelMention.ReplaceAllStringFunc(content, func(mention string) string {
+		id := patternChannelMention.FindStringSubmatch(mention)[1]
+		if channel, err := s.State.Channel(id); err == nil {
+			return "#" + channel.Name
+		}
+		return "#channel"
+	})
+
+	content = patternCustomEmoji.ReplaceAllString(content, ":$1:")
+	content = patternTimestamp.ReplaceAllStringFunc(content, func(ts string) string {
+		sec, err := strconv.ParseInt(patternTimestamp.FindStringSubmatch(ts)[1], 10, 64)
+		if err != nil {
+			return ts
+		}
+		return time.Unix(sec, 0).UTC().Format("2006-01-02 15:04 UTC")
+	})
+
+	content = emojiToText(content)
+	content = stripMarkdown(content)
+
+	var extras []string
+	for _, attachment := range m.Attachments {
+		extras = append(extras, fmt.Sprintf("[%v]", attachment.Filename))
+	}
+	for _, sticker := range m.StickerItems {
+		extras = append(extras, fmt.Sprintf("[sticker: %v]", sticker.Name))
+	}
+	if len(extras) > 0 {
+		content = strings.TrimSpace(content + " " + strings.Join(extras, " "))
+	}
+
+	return strings.TrimSpace(content)
+}
+
+// markdownChars may be escaped with a backslash in discord
+const markdownChars = "\\*_~|>#-`[]()"
+
+// escapeBase maps the escaped markdown characters to the private use area
+// while the markdown is stripped
+const escapeBase = 0xe000
+
+func stripMarkdown(content string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range content {
+		switch {
+		case escaped && strings.ContainsRune(markdownChars, r):
+			b.WriteRune(escapeBase + r)
+		case escaped:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			continue
+		default:
+			b.WriteRune(r)
+		}
+		escaped = false
+	}
+	if escaped {
+		b.WriteRune('\\')
+	}
+	content = b.String()
+
+	content = patternLink.ReplaceAllString(content, "$1 ($3)")
+	content = patternAngleLink.ReplaceAllString(content, "$1")
+	content = patternHeading.ReplaceAllString(content, "")
+	content = markdownReplacer.Replace(content)
+	content = patternEmphasis.ReplaceAllString(content, "$1$2$3")
+
+	content = strings.Map(func(r rune) rune {
+		if r >= escapeBase && r < escapeBase+128 {
+			return r - escapeBase
+		}
+		return r
+	}, content)
+
+	// the in-game chat is a single line
+	return strings.Join(strings.Fields(content), " ")
+}
+
+func emojiToText(content string) string {
+	var b strings.Builder
+	for _, r := range content {
+		if name, ok := emojiNames[r]; ok {
+			b.WriteString(name)
+			continue
+		}
+
+		// variation selectors, joiners and skin tones of emoji sequences
+		if r == '\u200d' || (r >= '\ufe00' && r <= '\ufe0f') || (r >= 0x1f3fb && r <= 0x1f3ff) {
+			continue
+		}
+
+		// emoji the in-game font can not show
+		if r >= 0x1f000 && unicode.Is(unicode.So, r) {
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// splitMessage splits content in parts of at most max characters, on spaces
+// when possible
+func splitMessage(content string, max int) (parts []string) {
+	for utf8.RuneCountInString(content) > max {
+		runes := []rune(content)
+
+		cut := max
+		for i := max; i > max/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+
+		parts = append(parts, strings.TrimSpace(string(runes[:cut])))
+		content = strings.TrimSpace(string(runes[cut:]))
+	}
+
+	if content != "" {
+		parts = append(parts, content)
+	}
+	return parts
+}
+
+// markdownEscaper escapes the discord markdown of in-game messages
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"~", `\~`,
+	"`", "\\`",
+	"|", `\|`,
+	">", `\>`,
+	"#", `\#`,
+	"[", `\[`,
+	"]", `\]`,
+)
+
+// discordContent escapes an in-game text for discord. Within a code block
+// only its fence needs care, elsewhere the markdown is escaped.
+func discordContent(text string, inCodeBlock bool) string {
+	// @everyone and @here never ping, also with AllowedMentions lost on the way
+	text = strings.NewReplacer("@everyone", "@\u200beveryone", "@here", "@\u200bhere").Replace(text)
+
+	if inCodeBlock {
+		return strings.ReplaceAll(text, "`", "ˋ")
+	}
+	return markdownEscaper.Replace(text)
+}
+
+// noMentions disables every mention of the messages the bot sends
+var noMentions = &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}}
@@ -0,0 +1,144 @@
+package chatbot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSession(t *testing.T) *discordgo.Session {
+	state := discordgo.NewState()
+	if err := state.GuildAdd(&discordgo.Guild{
+		ID: "1",
+		Roles: []*discordgo.Role{
+			{ID: "10", Name: "Admins", Mentionable: true},
+			{ID: "11", Name: "Quiet"},
+		},
+		Channels: []*discordgo.Channel{
+			{ID: "20", GuildID: "1", Name: "general"},
+			{ID: "21", GuildID: "1", Name: "ark-chat"},
+		},
+		Members: []*discordgo.Member{
+			{GuildID: "1", User: &discordgo.User{ID: "30", Username: "bob"}, Nick: "Bobby"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return &discordgo.Session{State: state, StateEnabled: true}
+}
+
+func TestGameContent(t *testing.T) {
+	s := testSession(t)
+	bob := &discordgo.User{ID: "30", Username: "bob"}
+
+	for _, tc := range []struct {
+		message *discordgo.Message
+		content string
+	}{
+		{&discordgo.Message{Content: "hello"}, "hello"},
+		{&discordgo.Message{Content: "hi <@30> and <@!30>", Mentions: []*discordgo.User{bob}}, "hi @bob and @Bobby"},
+		{&discordgo.Message{Content: "hi <@31>"}, "hi @user"},
+		{&discordgo.Message{Content: "<@&10> <@&11> <@&12>", MentionRoles: []string{"10", "11"}}, "@Admins @Quiet @role"},
+		{&discordgo.Message{Content: "see <#21> or <#22>"}, "see #ark-chat or #channel"},
+		{&discordgo.Message{Content: "nice <:pepe:123> <a:dance:456>"}, "nice :pepe: :dance:"},
+		{&discordgo.Message{Content: "at <t:0:R>, <t:1700000000>"}, "at 1970-01-01 00:00 UTC, 2023-11-14 22:13 UTC"},
+		{&discordgo.Message{Content: "**bold** 👍🏽\nnext line"}, "bold :+1: next line"},
+		{&discordgo.Message{
+			Content:      "look",
+			Attachments:  []*discordgo.MessageAttachment{{Filename: "base.png"}},
+			StickerItems: []*discordgo.StickerItem{{Name: "wave"}},
+		}, "look [base.png] [sticker: wave]"},
+		{&discordgo.Message{Attachments: []*discordgo.MessageAttachment{{Filename: "base.png"}}}, "[base.png]"},
+	} {
+		tc.message.ChannelID = "20"
+		tc.message.GuildID = "1"
+		assert.Equal(t, tc.content, gameContent(s, tc.message), tc.message.Content)
+	}
+}
+
+func TestStripMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		text    string
+	}{
+		{"plain text", "plain text"},
+		{"**bold** __under__ ~~strike~~ ||spoiler||", "bold under strike spoiler"},
+		{"*italic* and _italic_", "italic and italic"},
+		{"snake_case_name and 2*3*4", "snake_case_name and 2*3*4"},
+		{"`code` and ```block```", "code and block"},
+		{"# Heading\n> quote\n-# small", "Heading quote small"},
+		{"[the wiki](https://ark.wiki.gg) and [x](<https://example.com>)", "the wiki (https://ark.wiki.gg) and x (https://example.com)"},
+		{"<https://example.com/a>", "https://example.com/a"},
+		{`\*not italic\* and \_\_ and \# no heading`, "*not italic* and __ and # no heading"},
+		{`a \n b \\ c \`, `a \n b \ c \`},
+		{"multi\n\nline   text", "multi line text"},
+	} {
+		assert.Equal(t, tc.text, stripMarkdown(tc.content), tc.content)
+	}
+}
+
+func TestEmojiToText(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		text    string
+	}{
+		{"no emoji", "no emoji"},
+		{"hi 😀 🦖", "hi :D :t-rex:"},
+		{"❤️", "<3"},
+		{"👍🏿", ":+1:"},
+		{"👨‍👩‍👧 family", " family"},
+		{"🥳 party", " party"},
+		{"Bób ✓ ©", "Bób ✓ ©"},
+	} {
+		assert.Equal(t, tc.text, emojiToText(tc.content), tc.content)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		max     int
+		parts   []string
+	}{
+		{"", 10, nil},
+		{"0123456789", 10, []string{"0123456789"}},
+		{"0123456789a", 10, []string{"0123456789", "a"}},
+		{"012345 789a", 10, []string{"012345", "789a"}},
+		{"01234 6789a", 10, []string{"01234 6789", "a"}},
+		{"0123456789 abc", 10, []string{"0123456789", "abc"}},
+		{"012345678 abc", 10, []string{"012345678", "abc"}},
+		{"0123 56789abcdef", 10, []string{"0123 56789", "abcdef"}},
+		{"0123456789abcdefghijklmnopqrst", 10, []string{"0123456789", "abcdefghij", "klmnopqrst"}},
+		{"ééééééééééé", 10, []string{"éééééééééé", "é"}},
+		{"0123456789 ", 10, []string{"0123456789"}},
+	} {
+		parts := splitMessage(tc.content, tc.max)
+		assert.Equal(t, tc.parts, parts, tc.content)
+		for _, part := range parts {
+			assert.LessOrEqual(t, utf8.RuneCountInString(part), tc.max, part)
+		}
+	}
+
+	long := strings.Repeat("word ", 100)
+	parts := splitMessage(long, gameMessageMax)
+	assert.Equal(t, strings.TrimSpace(long), strings.Join(parts, " "))
+}
+
+func TestDiscordContent(t *testing.T) {
+	for _, tc := range []struct {
+		text        string
+		inCodeBlock bool
+		content     string
+	}{
+		{"hello", false, "hello"},
+		{"*bold* _x_ ~y~ `z` |w| > # [a](b) \\", false, `\*bold\* \_x\_ \~y\~ \` + "`z\\`" + ` \|w\| \> \# \[a\](b) \\`},
+		{"@everyone @here", false, "@\u200beveryone @\u200bhere"},
+		{"*bold* ```fence```", true, "*bold* ˋˋˋfenceˋˋˋ"},
+		{"@everyone", true, "@\u200beveryone"},
+	} {
+		assert.Equal(t, tc.content, discordContent(tc.text, tc.inCodeBlock), tc.text)
+	}
+}
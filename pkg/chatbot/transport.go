@@ -0,0 +1,20 @@
+package chatbot
+
+import (
+	"context"
+)
+
+// Transport carries the cluster chat list shared with the game servers
+type Transport interface {
+	LPush(ctx context.Context, key, value string) (err error)
+	LTrim(ctx context.Context, key string, start, end int) (err error)
+	LRange(ctx context.Context, key string, start, end int) (items []string, err error)
+
+	Publish(ctx context.Context, channel, value string) (err error)
+	// Subscribe calls fn for every message published on channel until ctx is
+	// done or the connection fails
+	Subscribe(ctx context.Context, channel string, fn func(value string)) (err error)
+	// Pop calls fn for every value LPUSHed on the list key, oldest first,
+	// taking it off the list, until ctx is done or the connection fails
+	Pop(ctx context.Context, key string, fn func(value string)) (err error)
+}
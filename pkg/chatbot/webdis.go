@@ -0,0 +1,209 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// Webdis is a Transport over the webdis HTTP interface
+type Webdis struct {
+	addr   string
+	client *http.Client
+}
+
+func NewWebdis(addr string) *Webdis {
+	return &Webdis{
+		addr:   addr,
+		client: new(http.Client),
+	}
+}
+
+func (webdis *Webdis) do(ctx context.Context, method, path string) (body []byte, err error) {
+	var req *http.Request
+	if method == "GET" {
+		req, err = http.NewRequestWithContext(ctx, method, fmt.Sprintf("%v/%v", webdis.addr, path), nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, webdis.addr, strings.NewReader(path))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "http.NewRequest")
+	}
+
+	resp, err := webdis.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "client.Do")
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "ioutil.ReadAll")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%v => %v %v", strings.SplitN(path, "/", 2)[0], resp.Status, string(body))
+	}
+
+	return body, nil
+}
+
+func (webdis *Webdis) LPush(ctx context.Context, key, value string) (err error) {
+	body, err := webdis.do(ctx, "POST", fmt.Sprintf("LPUSH/%v/%v", key, url.QueryEscape(value)))
+	if err != nil {
+		return errors.Wrap(err, "webdis.do")
+	}
+
+	log.Infof("LPUSH => %v", string(body))
+	return nil
+}
+
+func (webdis *Webdis) LTrim(ctx context.Context, key string, start, end int) (err error) {
+	body, err := webdis.do(ctx, "POST", fmt.Sprintf("LTRIM/%v/%v/%v", key, start, end))
+	if err != nil {
+		return errors.Wrap(err, "webdis.do")
+	}
+
+	log.Infof("LTRIM => %v", string(body))
+	return nil
+}
+
+func (webdis *Webdis) LRange(ctx context.Context, key string, start, end int) (items []string, err error) {
+	body, err := webdis.do(ctx, "GET", fmt.Sprintf("LRANGE/%v/%v/%v", key, start, end))
+	if err != nil {
+		return nil, errors.Wrap(err, "webdis.do")
+	}
+
+	// ERROR  {"LRANGE":[false,"ERR wrong number of arguments for 'lrange' command"]}
+	// NORMAL {"LRANGE":["....","....."]}
+	var result = map[string][]any{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+
+	if _, has := result["LRANGE"]; !has {
+		return nil, errors.Errorf("invalid result: LRANGE not exist")
+	}
+
+	arr := result["LRANGE"]
+	if len(arr) == 2 {
+		if b, ok := arr[0].(bool); ok && b == false {
+			return nil, errors.Errorf("LRANGE failure: %v", arr[1])
+		}
+	}
+
+	for _, item := range arr {
+		s, ok := item.(string)
+		if !ok {
+			return nil, errors.Errorf("LRANGE failure: unexpected item %v", item)
+		}
+		items = append(items, s)
+	}
+
+	return items, nil
+}
+
+func (webdis *Webdis) Publish(ctx context.Context, channel, value string) (err error) {
+	body, err := webdis.do(ctx, "POST", fmt.Sprintf("PUBLISH/%v/%v", channel, url.QueryEscape(value)))
+	if err != nil {
+		return errors.Wrap(err, "webdis.do")
+	}
+
+	log.Debugf("PUBLISH => %v", string(body))
+	return nil
+}
+
+// Subscribe uses the webdis streaming SUBSCRIBE, which keeps the response
+// open and writes one json object per message
+func (webdis *Webdis) Subscribe(ctx context.Context, channel string, fn func(value string)) (err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%v/SUBSCRIBE/%v", webdis.addr, channel), nil)
+	if err != nil {
+		return errors.Wrap(err, "http.NewRequest")
+	}
+
+	resp, err := webdis.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "client.Do")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("SUBSCRIBE => %v", resp.Status)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		// {"SUBSCRIBE":["subscribe","MyCluster",1]}
+		// {"SUBSCRIBE":["message","MyCluster","{...}"]}
+		var result = map[string][]any{}
+		if err := dec.Decode(&result); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Wrap(err, "json.Decode")
+		}
+
+		arr := result["SUBSCRIBE"]
+		if len(arr) != 3 {
+			return errors.Errorf("SUBSCRIBE failure: unexpected reply %v", result)
+		}
+
+		if kind, _ := arr[0].(string); kind != "message" {
+			continue
+		}
+
+		if payload, ok := arr[2].(string); ok {
+			fn(payload)
+		}
+	}
+}
+
+// Pop long polls BRPOP, webdis answers when a value is pushed or after the
+// timeout
+func (webdis *Webdis) Pop(ctx context.Context, key string, fn func(value string)) (err error) {
+	timeout := int(popTimeout / time.Second)
+	for {
+		body, err := webdis.do(ctx, "GET", fmt.Sprintf("BRPOP/%v/%v", key, timeout))
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.Wrap(err, "webdis.do")
+		}
+
+		// TIMEOUT {"BRPOP":null}
+		// NORMAL  {"BRPOP":["key","value"]}
+		var result = map[string]any{}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return errors.Wrap(err, "json.Unmarshal")
+		}
+
+		reply, has := result["BRPOP"]
+		if !has {
+			return errors.Errorf("invalid result: BRPOP not exist")
+		}
+		if reply == nil {
+			continue
+		}
+
+		arr, ok := reply.([]any)
+		if !ok || len(arr) != 2 {
+			return errors.Errorf("BRPOP failure: unexpected reply %v", reply)
+		}
+		if b, ok := arr[0].(bool); ok && b == false {
+			return errors.Errorf("BRPOP failure: %v", arr[1])
+		}
+
+		if value, ok := arr[1].(string); ok {
+			fn(value)
+		}
+	}
+}
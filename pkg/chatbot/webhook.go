@@ -0,0 +1,194 @@
+package chatbot
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+// display styles of in-game messages, per route
+const (
+	// StyleText sends the message formatted with Route.Format
+	StyleText = "text"
+	// StyleEmbed sends the message as an embed colored by the in-game chat color
+	StyleEmbed = "embed"
+)
+
+// webhookName is the name of the channel webhooks the bot creates
+const webhookName = "arktools"
+
+// discord limits the username of a webhook message to 80 characters
+const webhookUsernameMax = 80
+
+// webhooks caches the webhook of each channel
+type webhooks struct {
+	mu    sync.Mutex
+	hooks map[string]*discordgo.Webhook
+}
+
+// get returns the webhook of the channel, creating it when it does not exist
+func (w *webhooks) get(dg *discordgo.Session, channelId string) (hook *discordgo.Webhook, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if hook := w.hooks[channelId]; hook != nil {
+		return hook, nil
+	}
+
+	hooks, err := dg.ChannelWebhooks(channelId)
+	if err != nil {
+		return nil, errors.Wrap(err, "dg.ChannelWebhooks")
+	}
+
+	for _, h := range hooks {
+		// only the webhooks created by the bot carry a token
+		if h.Name == webhookName && h.Token != "" {
+			hook = h
+			break
+		}
+	}
+
+	if hook == nil {
+		hook, err = dg.WebhookCreate(channelId, webhookName, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "dg.WebhookCreate")
+		}
+	}
+
+	if w.hooks == nil {
+		w.hooks = map[string]*discordgo.Webhook{}
+	}
+	w.hooks[channelId] = hook
+	return hook, nil
+}
+
+// owns reports whether the webhook was created by the bot
+func (w *webhooks) owns(webhookId string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, hook := range w.hooks {
+		if hook.ID == webhookId {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidate forgets the webhook of the channel, deleted by someone else
+func (w *webhooks) invalidate(channelId string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.hooks, channelId)
+}
+
+// webhookUsername is "[Tribe] Survivor (Map)"
+func webhookUsername(msg *RedisMessage) string {
+	name := msg.SurvivorName
+	if msg.TribeName != "" {
+		name = fmt.Sprintf("[%v] %v", msg.TribeName, name)
+	}
+	if msg.ServerName != "" {
+		name = fmt.Sprintf("%v (%v)", name, msg.ServerName)
+	}
+	return truncate(name, webhookUsernameMax)
+}
+
+// embedColor converts the in-game chat color, rgba in 0..1, to 0xRRGGBB
+func embedColor(color []float64) int {
+	if len(color) < 3 {
+		return 0
+	}
+
+	c := 0
+	for _, v := range color[:3] {
+		c = c<<8 | int(math.Round(math.Max(0, math.Min(1, v))*255))
+	}
+	return c
+}
+
+func (route *Route) avatar(server string) string {
+	if avatar, ok := route.Avatars[server]; ok {
+		return avatar
+	}
+	return route.Avatar
+}
+
+// sendWebhook sends the messages of one survivor through the channel webhook
+func (d *Discord) sendWebhook(route *Route, msgs []*RedisMessage, opts ...discordgo.RequestOption) (err error) {
+	hook, err := d.webhooks.get(d.dg, route.ChannelId)
+	if err != nil {
+		return errors.Wrap(err, "webhooks.get")
+	}
+
+	params := &discordgo.WebhookParams{
+		Username:        webhookUsername(msgs[0]),
+		AvatarURL:       route.avatar(msgs[0].ServerName),
+		AllowedMentions: noMentions,
+	}
+
+	var lines []string
+	for _, msg := range msgs {
+		if route.Style == StyleEmbed {
+			params.Embeds = append(params.Embeds, &discordgo.MessageEmbed{
+				Description: discordContent(msg.Message, false),
+				Color:       embedColor(msg.Color),
+			})
+		} else {
+			lines = append(lines, discordContent(msg.Message, false))
+		}
+	}
+	params.Content = truncate(strings.Join(lines, "\n"), discordMessageMax)
+
+	if _, err := d.dg.WebhookExecute(hook.ID, hook.Token, false, params, opts...); err != nil {
+		var restErr *discordgo.RESTError
+		if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+			// deleted by someone else, created again on the next message
+			d.webhooks.invalidate(route.ChannelId)
+		}
+		return errors.Wrap(err, "dg.WebhookExecute")
+	}
+
+	return nil
+}
+
+// sendBot sends the messages as the bot user
+func (d *Discord) sendBot(route *Route, msgs []*RedisMessage, opts ...discordgo.RequestOption) (err error) {
+	data := &discordgo.MessageSend{AllowedMentions: noMentions}
+
+	var lines []string
+	for _, msg := range msgs {
+		if route.Style == StyleEmbed {
+			data.Embeds = append(data.Embeds, &discordgo.MessageEmbed{
+				Author:      &discordgo.MessageEmbedAuthor{Name: webhookUsername(msg), IconURL: route.avatar(msg.ServerName)},
+				Description: discordContent(msg.Message, false),
+				Color:       embedColor(msg.Color),
+			})
+		} else {
+			lines = append(lines, renderText(route, msg))
+		}
+	}
+	data.Content = truncate(strings.Join(lines, "\n"), discordMessageMax)
+
+	if _, err := d.dg.ChannelMessageSendComplex(route.ChannelId, data, opts...); err != nil {
+		return errors.Wrap(err, "dg.ChannelMessageSendComplex")
+	}
+	return nil
+}
+
+// renderText formats an in-game message with the route format
+func renderText(route *Route, msg *RedisMessage) string {
+	inCodeBlock := strings.Contains(route.Format, "```")
+	return fmt.Sprintf(route.Format,
+		discordContent(msg.ServerName, inCodeBlock),
+		discordContent(msg.TribeName, inCodeBlock),
+		discordContent(msg.SurvivorName, inCodeBlock),
+		discordContent(msg.Message, inCodeBlock),
+	)
+}
@@ -0,0 +1,192 @@
+package chatlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// directions of an archived message, other bridges are named in lower case
+const (
+	// FromGame is an in-game message forwarded to the bridges
+	FromGame = "game"
+	// FromDiscord is a discord message sent to the game
+	FromDiscord = "discord"
+)
+
+// dayLayout names the file of each day, the files are the index of the archive
+const dayLayout = "2006-01-02"
+
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Cluster   string    `json:"cluster"`
+	Server    string    `json:"server"`
+	Tribe     string    `json:"tribe"`
+	Survivor  string    `json:"survivor"`
+	Message   string    `json:"message"`
+}
+
+func (entry *Entry) String() string {
+	return fmt.Sprintf("%v [%v][%v][%v]: %v",
+		entry.Time.Local().Format("2006-01-02 15:04:05"),
+		entry.Server, entry.Tribe, entry.Survivor, entry.Message)
+}
+
+// Query selects entries of Search, zero values match everything
+type Query struct {
+	// Player matches the survivor name, case insensitive
+	Player string
+	// Since is the oldest time of the entries
+	Since time.Time
+	// Contains matches the message, case insensitive
+	Contains string
+	// Limit keeps only the newest entries when > 0
+	Limit int
+}
+
+func (q *Query) match(entry *Entry) bool {
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+
+	if q.Player != "" && !strings.EqualFold(entry.Survivor, q.Player) {
+		return false
+	}
+
+	if q.Contains != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(q.Contains)) {
+		return false
+	}
+
+	return true
+}
+
+// Archive is an append-only store of chat messages, one json lines file
+// per day (UTC) in dir
+type Archive struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func Open(dir string) (archive *Archive, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "os.MkdirAll(%v)", dir)
+	}
+
+	return &Archive{dir: dir}, nil
+}
+
+func (archive *Archive) Append(entry *Entry) (err error) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "json.Marshal")
+	}
+
+	path := filepath.Join(archive.dir, entry.Time.UTC().Format(dayLayout)+".jsonl")
+
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "os.OpenFile(%v)", path)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return errors.Wrapf(err, "Write(%v)", path)
+	}
+
+	return nil
+}
+
+// Search returns the entries matching q, oldest first
+func (archive *Archive) Search(q *Query) (entries []*Entry, err error) {
+	days, err := archive.days()
+	if err != nil {
+		return nil, errors.Wrap(err, "archive.days")
+	}
+
+	for _, day := range days {
+		// files of the days before since can not match
+		if !q.Since.IsZero() && day.AddDate(0, 0, 1).Before(q.Since) {
+			continue
+		}
+
+		found, err := archive.searchDay(day, q)
+		if err != nil {
+			return nil, errors.Wrapf(err, "archive.searchDay(%v)", day.Format(dayLayout))
+		}
+		entries = append(entries, found...)
+	}
+
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+
+	return entries, nil
+}
+
+// days returns the days of the archive, oldest first
+func (archive *Archive) days() (days []time.Time, err error) {
+	files, err := ioutil.ReadDir(archive.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ioutil.ReadDir(%v)", archive.dir)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+
+		day, err := time.Parse(dayLayout, strings.TrimSuffix(name, ".jsonl"))
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+func (archive *Archive) searchDay(day time.Time, q *Query) (entries []*Entry, err error) {
+	path := filepath.Join(archive.dir, day.Format(dayLayout)+".jsonl")
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "os.Open(%v)", path)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var entry *Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a line cut by a crash
+			continue
+		}
+
+		if q.match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "Scan(%v)", path)
+	}
+
+	return entries, nil
+}
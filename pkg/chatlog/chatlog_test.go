@@ -0,0 +1,109 @@
+package chatlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendRollover(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	archive, err := Open(dir)
+	assert.Nil(t, err)
+
+	// the file of the day is of the UTC date, not the local one
+	kst := time.FixedZone("KST", 9*60*60)
+	for _, entry := range []*Entry{
+		{Time: time.Date(2026, 10, 16, 23, 59, 59, 0, time.UTC), Survivor: "Bob", Message: "a"},
+		{Time: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), Survivor: "Bob", Message: "b"},
+		{Time: time.Date(2026, 10, 17, 8, 0, 0, 0, kst), Survivor: "Bob", Message: "c"},
+		{Time: time.Date(2026, 10, 18, 8, 0, 0, 0, kst), Survivor: "Bob", Message: "d"},
+	} {
+		assert.Nil(t, archive.Append(entry))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "2026-10-16.jsonl"),
+		filepath.Join(dir, "2026-10-17.jsonl"),
+	}, files)
+
+	b, err := os.ReadFile(filepath.Join(dir, "2026-10-16.jsonl"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"message":"c"`)
+
+	entries, err := archive.Search(&Query{})
+	assert.Nil(t, err)
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"a", "c", "b", "d"}, messages)
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := Open(dir)
+	assert.Nil(t, err)
+
+	day := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	for i, entry := range []*Entry{
+		{Survivor: "Bob", Message: "Hello there"},
+		{Survivor: "alice", Message: "hi Bob"},
+		{Survivor: "BOB", Message: "tame a rex"},
+		{Survivor: "Carol", Message: "HELLO"},
+		{Survivor: "bob", Message: "bye"},
+	} {
+		entry.Time = day.AddDate(0, 0, i)
+		entry.Direction = FromGame
+		assert.Nil(t, archive.Append(entry))
+	}
+
+	// a line cut by a crash and files which are not days are skipped
+	f, err := os.OpenFile(filepath.Join(dir, "2026-10-12.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.Nil(t, err)
+	_, err = f.WriteString(`{"time":"2026-10-12T13:00:00Z","survivor":"Bob","mess` + "\n")
+	assert.Nil(t, err)
+	f.Close()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "notes.jsonl"), []byte(`{"survivor":"Bob"}`+"\n"), 0644))
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "2026-10-20.jsonl"), 0755))
+
+	for _, tc := range []struct {
+		name     string
+		query    *Query
+		messages []string
+	}{
+		{"all", &Query{}, []string{"Hello there", "hi Bob", "tame a rex", "HELLO", "bye"}},
+		{"player", &Query{Player: "bob"}, []string{"Hello there", "tame a rex", "bye"}},
+		{"contains", &Query{Contains: "hello"}, []string{"Hello there", "HELLO"}},
+		{"player and contains", &Query{Player: "BOB", Contains: "REX"}, []string{"tame a rex"}},
+		{"since", &Query{Since: day.AddDate(0, 0, 3)}, []string{"HELLO", "bye"}},
+		{"since within a day", &Query{Since: day.AddDate(0, 0, 3).Add(time.Second)}, []string{"bye"}},
+		{"limit keeps the newest", &Query{Player: "bob", Limit: 2}, []string{"tame a rex", "bye"}},
+		{"limit above the matches", &Query{Limit: 10}, []string{"Hello there", "hi Bob", "tame a rex", "HELLO", "bye"}},
+		{"no match", &Query{Player: "dave"}, nil},
+		{"after the last", &Query{Since: day.AddDate(0, 1, 0)}, nil},
+	} {
+		entries, err := archive.Search(tc.query)
+		assert.Nil(t, err, tc.name)
+
+		var messages []string
+		for _, entry := range entries {
+			messages = append(messages, entry.Message)
+		}
+		assert.Equal(t, tc.messages, messages, tc.name)
+	}
+}
+
+func TestSearchEmpty(t *testing.T) {
+	archive, err := Open(t.TempDir())
+	assert.Nil(t, err)
+
+	entries, err := archive.Search(&Query{Limit: 5})
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,315 @@
+// Package clustersav reads and writes the cluster transfer files of the FCSS
+// mod, Players/<steamid>.sav, which hold the survivor, items and dinos a
+// player uploaded as JSON.
+//
+// A file is three length prefixed header strings, the uint64 size of the
+// data followed by the data as an UE4 string: its int32 character count,
+// negative when it is UTF-16LE, and the null terminated characters.
+package clustersav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// ErrTruncated is returned, wrapped, when a file ends before its lengths say
+var ErrTruncated = errors.New("truncated sav")
+
+// Header is the three strings before the data, in the order of the file.
+// arktools does not interpret them, a string that was not changed is written
+// back as it was read, in its own encoding.
+type Header struct {
+	Class   string
+	Version string
+	Owner   string
+
+	// raw are the strings as read, in the order of the file
+	raw [3]rawString
+}
+
+// rawString is a string of the file and its bytes with the length
+type rawString struct {
+	s string
+	b []byte
+}
+
+func (header *Header) strings() []*string {
+	return []*string{&header.Class, &header.Version, &header.Owner}
+}
+
+// Sav is a parsed cluster transfer file
+type Sav struct {
+	Header Header
+
+	// Utf16 is whether Data is stored as UTF-16LE in the file
+	Utf16 bool
+	// Data is the JSON of the upload, UTF-8 without the nulls around it
+	Data []byte
+	// leading are the nulls before the JSON, written back by Bytes
+	leading []byte
+	// Trailer is what follows the data in the file, written back unchanged
+	Trailer []byte
+}
+
+// Parse parses a cluster transfer file
+func Parse(data []byte) (sav *Sav, err error) {
+	sav = new(Sav)
+	r := &reader{data: data}
+
+	for idx, field := range sav.Header.strings() {
+		from := r.pos
+		*field, err = r.string()
+		if err != nil {
+			return nil, errors.Wrap(err, "header")
+		}
+		sav.Header.raw[idx] = rawString{s: *field, b: append([]byte{}, data[from:r.pos]...)}
+	}
+
+	size, err := r.uint64()
+	if err != nil {
+		return nil, errors.Wrap(err, "data size")
+	}
+	if size < 4 {
+		return nil, errors.Errorf("data size %v at %v is less than 4", size, r.pos-8)
+	}
+
+	body, err := r.bytes(size)
+	if err != nil {
+		return nil, errors.Wrap(err, "data")
+	}
+
+	count := int32(binary.LittleEndian.Uint32(body))
+	body = body[4:]
+
+	// only the sign of the count matters, size is the length of the data
+	sav.Utf16 = count < 0
+	if sav.Utf16 {
+		if body, err = ToUtf8(body); err != nil {
+			return nil, errors.Wrap(err, "ToUtf8")
+		}
+	}
+
+	body = bytes.TrimRight(body, "\x00")
+	sav.Data = bytes.TrimLeft(body, "\x00")
+	if n := len(body) - len(sav.Data); n > 0 {
+		sav.leading = make([]byte, n)
+	}
+	sav.Trailer = r.rest()
+	return sav, nil
+}
+
+// ReadFile parses the cluster transfer file at path
+func ReadFile(path string) (sav *Sav, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "ioutil.ReadFile")
+	}
+
+	sav, err = Parse(data)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+	return sav, nil
+}
+
+// ReadSav returns the JSON data of the cluster transfer file at path
+func ReadSav(path string) (out []byte, err error) {
+	sav, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return sav.Data, nil
+}
+
+// Bytes encodes the file, recomputing the lengths of the strings and data
+func (sav *Sav) Bytes() (data []byte, err error) {
+	buf := new(bytes.Buffer)
+
+	for idx, field := range sav.Header.strings() {
+		if raw := sav.Header.raw[idx]; raw.b != nil && raw.s == *field {
+			buf.Write(raw.b)
+			continue
+		}
+		if err := writeString(buf, *field); err != nil {
+			return nil, errors.Wrap(err, "header")
+		}
+	}
+
+	body := append(append(append([]byte{}, sav.leading...), sav.Data...), 0)
+	count := len(body)
+	if sav.Utf16 {
+		if body, err = ToUtf16le(body); err != nil {
+			return nil, errors.Wrap(err, "ToUtf16le")
+		}
+		count = -len(body) / 2
+	}
+	if len(body) > math.MaxInt32 {
+		return nil, errors.Errorf("data of %v bytes is too long", len(body))
+	}
+
+	binary.Write(buf, binary.LittleEndian, uint64(4+len(body)))
+	binary.Write(buf, binary.LittleEndian, int32(count))
+	buf.Write(body)
+	buf.Write(sav.Trailer)
+
+	return buf.Bytes(), nil
+}
+
+// WriteFile writes the file to path, through a temporary file so a crash
+// never leaves a truncated one
+func (sav *Sav) WriteFile(path string) (err error) {
+	data, err := sav.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "sav.Bytes")
+	}
+	return WriteFile(path, data)
+}
+
+// WriteFile writes the encoded file data to path like Sav.WriteFile, it
+// keeps the mode of the file it replaces
+func WriteFile(path string, data []byte) (err error) {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "ioutil.TempFile")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "Write")
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "Chmod")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "os.Rename(%v)", path)
+	}
+	return nil
+}
+
+// reader reads the fields of a file, checking every length against what is
+// left
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) bytes(n uint64) (b []byte, err error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errors.Wrapf(ErrTruncated, "%v bytes at %v, %v left", n, r.pos, len(r.data)-r.pos)
+	}
+	b = r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *reader) uint64() (v uint64, err error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (r *reader) string() (s string, err error) {
+	s, nread, err := readString(r.data[r.pos:])
+	if err != nil {
+		return "", errors.Wrapf(err, "string at %v", r.pos)
+	}
+	r.pos += nread
+	return s, nil
+}
+
+func (r *reader) rest() []byte {
+	if r.pos == len(r.data) {
+		return nil
+	}
+	return append([]byte{}, r.data[r.pos:]...)
+}
+
+// readString reads an UE4 string: its int32 length, negative for UTF-16LE,
+// and the null terminated characters
+func readString(data []byte) (s string, nread int, err error) {
+	if len(data) < 4 {
+		return "", 0, errors.Wrapf(ErrTruncated, "string length, %v bytes left", len(data))
+	}
+	l := int64(int32(binary.LittleEndian.Uint32(data)))
+	data = data[4:]
+
+	utf16 := l < 0
+	if utf16 {
+		l = -l * 2
+	}
+
+	if l > int64(len(data)) {
+		return "", 0, errors.Wrapf(ErrTruncated, "string of %v bytes, %v bytes left", l, len(data))
+	}
+
+	b := data[:l]
+	if utf16 {
+		if b, err = ToUtf8(b); err != nil {
+			return "", 0, errors.Wrap(err, "ToUtf8")
+		}
+	}
+
+	return string(bytes.TrimRight(b, "\x00")), int(l) + 4, nil
+}
+
+// writeString writes s as a null terminated UE4 string, UTF-16LE when it is
+// not ASCII as the engine does. Header strings that were not changed are
+// written as they were read instead.
+func writeString(buf *bytes.Buffer, s string) (err error) {
+	b := []byte(s + "\x00")
+	l := len(b)
+
+	if !isASCII(s) && utf8.ValidString(s) {
+		if b, err = ToUtf16le(b); err != nil {
+			return errors.Wrap(err, "ToUtf16le")
+		}
+		l = -len(b) / 2
+	}
+
+	binary.Write(buf, binary.LittleEndian, int32(l))
+	buf.Write(b)
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func ToUtf16le(utf8 []byte) (utf16le []byte, err error) {
+	utf16le, _, err = transform.Bytes(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder(), utf8)
+	return
+}
+
+func ToUtf8(utf16le []byte) (utf8 []byte, err error) {
+	utf8, _, err = transform.Bytes(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder(), utf16le)
+	return
+}
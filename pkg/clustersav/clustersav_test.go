@@ -0,0 +1,163 @@
+package clustersav
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func seeds(t testing.TB) [][]byte {
+	var out [][]byte
+	for _, sav := range []*Sav{
+		{Header: Header{Class: "FCSS", Version: "1", Owner: "76561198000000000"}, Data: []byte(`{"name":"Bob"}`)},
+		{Header: Header{Class: "FCSS", Owner: "Bób"}, Utf16: true, Data: []byte(`{"name":"Bób 🦖"}`)},
+		{Data: []byte(`{}`), Trailer: []byte{1, 2, 3}},
+		{Data: []byte(`{"SteamId":"1"}`), leading: []byte{0, 0}},
+	} {
+		data, err := sav.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, data)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, data := range seeds(t) {
+		sav, err := Parse(data)
+		assert.Nil(t, err)
+
+		again, err := sav.Bytes()
+		assert.Nil(t, err)
+		assert.Equal(t, data, again)
+	}
+}
+
+func TestRoundTripEncodings(t *testing.T) {
+	data := []byte{}
+	// FCSS as UTF-16LE, an empty string without terminator, 1 as 8-bit
+	data = append(data, "\xfb\xff\xff\xffF\x00C\x00S\x00S\x00\x00\x00"...)
+	data = append(data, "\x00\x00\x00\x00"...)
+	data = append(data, "\x02\x00\x00\x001\x00"...)
+	// the leading null of the data is written back, not decoded
+	data = append(data, "\x08\x00\x00\x00\x00\x00\x00\x00\x04\x00\x00\x00\x00{}\x00"...)
+
+	sav, err := Parse(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "FCSS", sav.Header.Class)
+	assert.Equal(t, "", sav.Header.Version)
+	assert.Equal(t, "1", sav.Header.Owner)
+	assert.Equal(t, []byte("{}"), sav.Data)
+
+	again, err := sav.Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, data, again)
+
+	// changed strings are encoded as the engine does
+	sav.Header.Class = "FCSS2"
+	sav.Header.Owner = "Bób"
+	again, err = sav.Bytes()
+	assert.Nil(t, err)
+
+	changed, err := Parse(again)
+	assert.Nil(t, err)
+	assert.Equal(t, "FCSS2", changed.Header.Class)
+	assert.Equal(t, "", changed.Header.Version)
+	assert.Equal(t, "Bób", changed.Header.Owner)
+	assert.True(t, bytes.HasPrefix(again, []byte("\x06\x00\x00\x00FCSS2\x00\x00\x00\x00\x00\xfc\xff\xff\xffB\x00\xf3\x00b\x00\x00\x00")))
+}
+
+func TestLeadingNulls(t *testing.T) {
+	sav, err := Parse(seeds(t)[3])
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(`{"SteamId":"1"}`), sav.Data)
+
+	player, err := Decode(sav.Data)
+	assert.Nil(t, err)
+	assert.Equal(t, ID("1"), player.SteamId)
+
+	// an edit keeps the nulls before the data
+	sav.Data = []byte(`{"SteamId":"2"}`)
+	data, err := sav.Bytes()
+	assert.Nil(t, err)
+	assert.True(t, bytes.Contains(data, []byte("\x00\x00{\"SteamId\":\"2\"}\x00")))
+}
+
+func TestTruncated(t *testing.T) {
+	data := seeds(t)[0]
+	for n := 0; n < len(data)-1; n++ {
+		_, err := Parse(data[:n])
+		assert.NotNil(t, err, "truncated at %v", n)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, data := range seeds(f) {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sav, err := Parse(data)
+		if err != nil {
+			return
+		}
+		if len(sav.Data) > 0 && (sav.Data[0] == 0 || sav.Data[len(sav.Data)-1] == 0) {
+			t.Fatalf("nulls around the data: %q", sav.Data)
+		}
+
+		out, err := sav.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes: %v", err)
+		}
+
+		again, err := Parse(out)
+		if err != nil {
+			t.Fatalf("Parse of Bytes: %v", err)
+		}
+		assert.Equal(t, sav, again)
+	})
+}
+
+func FuzzReadString(f *testing.F) {
+	f.Add([]byte("\x06\x00\x00\x00hello\x00"))
+	f.Add([]byte("\xfe\xff\xff\xffh\x00\x00\x00"))
+	f.Add([]byte("\xff\xff\xff\x7f"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s, nread, err := readString(data)
+		if err != nil {
+			return
+		}
+		if nread > len(data) {
+			t.Fatalf("read %v of %v bytes", nread, len(data))
+		}
+
+		buf := new(bytes.Buffer)
+		assert.Nil(t, writeString(buf, s))
+
+		again, _, err := readString(buf.Bytes())
+		assert.Nil(t, err)
+		assert.Equal(t, s, again)
+	})
+}
+
+func FuzzUtf16(f *testing.F) {
+	f.Add("hello")
+	f.Add("Bób 🦖")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			return
+		}
+
+		utf16le, err := ToUtf16le([]byte(s))
+		assert.Nil(t, err)
+
+		out, err := ToUtf8(utf16le)
+		assert.Nil(t, err)
+		assert.Equal(t, s, string(out))
+	})
+}
@@ -0,0 +1,101 @@
+package clustersav
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RemoveItems removes the items match selects from the JSON data of a
+// player. The other fields, also those the Player model does not know, are
+// kept as the mod wrote them.
+func RemoveItems(data []byte, match func(item *Item) bool) (out []byte, removed []*Item, err error) {
+	start, end, err := itemsValue(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if start == end || bytes.Equal(data[start:end], []byte("null")) {
+		return data, nil, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data[start:end], &items); err != nil {
+		return nil, nil, errors.Wrap(err, "json.Unmarshal(items)")
+	}
+
+	kept := []json.RawMessage{}
+	for idx, raw := range items {
+		var item *Item
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, nil, errors.Wrapf(err, "item %v", idx)
+		}
+
+		if item != nil && match(item) {
+			removed = append(removed, item)
+			continue
+		}
+		kept = append(kept, raw)
+	}
+
+	if len(removed) == 0 {
+		return data, nil, nil
+	}
+
+	b, err := marshal(kept)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// only the items are replaced, the order and spacing of the rest stay
+	out = append(out, data[:start]...)
+	out = append(out, b...)
+	out = append(out, data[end:]...)
+	return out, removed, nil
+}
+
+// itemsValue returns the offsets of the value of the "Items" field of the
+// JSON object data, start == end when it has none. Keys match case
+// insensitively and the last one wins, as json.Unmarshal does.
+func itemsValue(data []byte) (start, end int, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return 0, 0, errors.Wrap(err, "json.Token")
+	} else if tok != json.Delim('{') {
+		return 0, 0, errors.Errorf("player data is not a JSON object")
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "json.Token")
+		}
+		key, _ := tok.(string)
+
+		from := int(dec.InputOffset())
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, 0, errors.Wrapf(err, "json.Decode(%v)", key)
+		}
+		to := int(dec.InputOffset())
+
+		if strings.EqualFold(key, "Items") {
+			// skip the colon and spaces between the key and the value
+			start, end = to-len(bytes.TrimLeft(data[from:to], " \t\r\n:")), to
+		}
+	}
+	return start, end, nil
+}
+
+// marshal encodes v without escaping <, > and &, the strings of the data
+// stay as they were
+func marshal(v any) (b []byte, err error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, errors.Wrap(err, "json.Encode")
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
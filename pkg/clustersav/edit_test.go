@@ -0,0 +1,85 @@
+package clustersav
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemsValue(t *testing.T) {
+	for _, tc := range []struct {
+		data  string
+		items string
+	}{
+		{`{"Items":[1]}`, `[1]`},
+		{`{ "Items" : [1] , "Dinos":[]}`, `[1]`},
+		{"{\"items\":\n\t[1]}", `[1]`},
+		{`{"ITEMS":[1],"Items":[2]}`, `[2]`},
+		{`{"Items":[1],"items":null}`, `null`},
+		{`{"Dinos":{"Items":[1]}}`, ``},
+		{`{"Name":"Items"}`, ``},
+		{`{}`, ``},
+	} {
+		start, end, err := itemsValue([]byte(tc.data))
+		assert.Nil(t, err, tc.data)
+		assert.Equal(t, tc.items, tc.data[start:end], tc.data)
+	}
+
+	for _, data := range []string{``, `[]`, `"Items"`, `{"Items":[1}`} {
+		_, _, err := itemsValue([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+}
+
+func TestRemoveItems(t *testing.T) {
+	isPike := func(item *Item) bool { return ShortClass(item.Class) == "PrimalItem_WeaponPike_C" }
+
+	for _, tc := range []struct {
+		name    string
+		data    string
+		out     string
+		removed int
+	}{
+		{
+			name:    "rest byte-identical",
+			data:    "{ \"SteamId\" : 76561198000000000,\n  \"Unknown\": {\"a\":\"<&>\"},\n  \"Items\": [ {\"Class\":\"PrimalItem_WeaponPike_C\"} , {\"Class\":\"PrimalItemArmor_RiotHelmet_C\",\"Extra\":\"kept <&>\"} ],\n  \"Dinos\": [] }",
+			out:     "{ \"SteamId\" : 76561198000000000,\n  \"Unknown\": {\"a\":\"<&>\"},\n  \"Items\": [{\"Class\":\"PrimalItemArmor_RiotHelmet_C\",\"Extra\":\"kept <&>\"}],\n  \"Dinos\": [] }",
+			removed: 1,
+		},
+		{
+			name:    "case-insensitive key",
+			data:    `{"items":[{"Class":"PrimalItem_WeaponPike_C"}],"Name":"x"}`,
+			out:     `{"items":[],"Name":"x"}`,
+			removed: 1,
+		},
+		{
+			name:    "last key wins",
+			data:    `{"Items":[{"Class":"PrimalItem_WeaponPike_C"}],"ITEMS":[{"Class":"PrimalItem_WeaponPike_C"},{"Class":"PrimalItem_WeaponPike_C"}]}`,
+			out:     `{"Items":[{"Class":"PrimalItem_WeaponPike_C"}],"ITEMS":[]}`,
+			removed: 2,
+		},
+		{
+			name: "nothing matches",
+			data: `{"Items": [ {"Class":"PrimalItemArmor_RiotHelmet_C"} ]}`,
+			out:  `{"Items": [ {"Class":"PrimalItemArmor_RiotHelmet_C"} ]}`,
+		},
+		{
+			name: "null items",
+			data: `{"Items":null}`,
+			out:  `{"Items":null}`,
+		},
+		{
+			name: "no items",
+			data: `{"Dinos":[{"Class":"PrimalItem_WeaponPike_C"}]}`,
+			out:  `{"Dinos":[{"Class":"PrimalItem_WeaponPike_C"}]}`,
+		},
+	} {
+		out, removed, err := RemoveItems([]byte(tc.data), isPike)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.out, string(out), tc.name)
+		assert.Equal(t, tc.removed, len(removed), tc.name)
+	}
+
+	_, _, err := RemoveItems([]byte(`{"Items":{"Class":"PrimalItem_WeaponPike_C"}}`), isPike)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,155 @@
+package clustersav
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Player is the cluster data of a player, the JSON data of its
+// Players/<steamid>.sav
+type Player struct {
+	SteamId  ID        `json:"SteamId"`
+	Survivor *Survivor `json:"Survivor,omitempty"`
+	Items    []*Item   `json:"Items"`
+	Dinos    []*Dino   `json:"Dinos"`
+
+	// Path is the file the player was read from
+	Path string `json:"-"`
+	// Data is the JSON the player was decoded from, with the fields the
+	// model does not know
+	Data []byte `json:"-"`
+}
+
+// Survivor is the uploaded character of a player
+type Survivor struct {
+	Name    string `json:"Name"`
+	Tribe   string `json:"Tribe"`
+	TribeId ID     `json:"TribeId,omitempty"`
+	Level   int    `json:"Level"`
+}
+
+// Item is an uploaded item
+type Item struct {
+	Id        ID      `json:"Id,omitempty"`
+	Class     string  `json:"Class"`
+	Name      string  `json:"Name"`
+	Quantity  int     `json:"Quantity"`
+	Quality   float64 `json:"Quality,omitempty"`
+	Blueprint bool    `json:"IsBlueprint,omitempty"`
+}
+
+// Dino is an uploaded creature
+type Dino struct {
+	Id     ID     `json:"Id,omitempty"`
+	Class  string `json:"Class"`
+	Name   string `json:"Name"`
+	Level  int    `json:"Level"`
+	Gender string `json:"Gender,omitempty"`
+}
+
+// ID is an id the mod writes as a JSON string or number
+type ID string
+
+func (id *ID) UnmarshalJSON(b []byte) (err error) {
+	if bytes.Equal(b, []byte("null")) {
+		*id = ""
+		return nil
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*id = ID(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return errors.Errorf("id is neither a string nor a number: %s", b)
+	}
+	*id = ID(n)
+	return nil
+}
+
+// Name is the survivor name, empty when no survivor was uploaded
+func (player *Player) Name() string {
+	if player.Survivor == nil {
+		return ""
+	}
+	return player.Survivor.Name
+}
+
+// Tribe is the tribe of the survivor
+func (player *Player) Tribe() string {
+	if player.Survivor == nil {
+		return ""
+	}
+	return player.Survivor.Tribe
+}
+
+// ShortClass is the class name without the blueprint path, e.g.
+// PrimalItemArmor_RiotHelmet of
+// Blueprint'/Game/.../PrimalItemArmor_RiotHelmet.PrimalItemArmor_RiotHelmet'
+func ShortClass(class string) string {
+	class = strings.TrimSuffix(class, "'")
+	if pos := strings.LastIndexAny(class, "./"); pos != -1 {
+		class = class[pos+1:]
+	}
+	return class
+}
+
+// Decode decodes the JSON data of a cluster transfer file, nulls around it
+// are ignored
+func Decode(data []byte) (player *Player, err error) {
+	if err := json.Unmarshal(bytes.Trim(data, "\x00"), &player); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal")
+	}
+	if player == nil {
+		return nil, errors.Errorf("no player data")
+	}
+	return player, nil
+}
+
+// ReadPlayer reads the player of the cluster transfer file at path, its
+// steam id is the file name when the data has none
+func ReadPlayer(path string) (player *Player, err error) {
+	data, err := ReadSav(path)
+	if err != nil {
+		return nil, err
+	}
+
+	player, err = Decode(data)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	if player.SteamId == "" {
+		player.SteamId = ID(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	player.Path = path
+	player.Data = data
+	return player, nil
+}
+
+// ReadPlayers reads the players of the Players/*.sav files of the cluster dir
+func ReadPlayers(clusterDir string) (players []*Player, err error) {
+	matches, err := filepath.Glob(filepath.Join(clusterDir, "Players", "*.sav"))
+	if err != nil {
+		return nil, errors.Wrap(err, "filepath.Glob")
+	}
+
+	for _, path := range matches {
+		player, err := ReadPlayer(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "ReadPlayer")
+		}
+		players = append(players, player)
+	}
+	return players, nil
+}
@@ -0,0 +1,97 @@
+package clustersav
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDUnmarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		id   ID
+	}{
+		{`"76561198000000000"`, "76561198000000000"},
+		{`76561198000000000`, "76561198000000000"},
+		{`123456789012345678901234567890`, "123456789012345678901234567890"},
+		{`-1`, "-1"},
+		{`1.5e3`, "1.5e3"},
+		{`""`, ""},
+		{`"a\"b"`, `a"b`},
+		{`null`, ""},
+	} {
+		id := ID("before")
+		assert.Nil(t, json.Unmarshal([]byte(tc.data), &id), tc.data)
+		assert.Equal(t, tc.id, id, tc.data)
+	}
+
+	for _, data := range []string{`true`, `{}`, `[1]`, `"unterminated`} {
+		var id ID
+		assert.NotNil(t, json.Unmarshal([]byte(data), &id), data)
+	}
+}
+
+func TestDecodeIDs(t *testing.T) {
+	player, err := Decode([]byte(`{"SteamId":76561198000000000,"Survivor":{"Name":"Bob","TribeId":"1234"},"Items":[{"Id":5,"Class":"C"}],"Dinos":[{"Id":null}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, ID("76561198000000000"), player.SteamId)
+	assert.Equal(t, ID("1234"), player.Survivor.TribeId)
+	assert.Equal(t, ID("5"), player.Items[0].Id)
+	assert.Equal(t, ID(""), player.Dinos[0].Id)
+
+	_, err = Decode([]byte(`null`))
+	assert.NotNil(t, err)
+}
+
+func TestShortClass(t *testing.T) {
+	for _, tc := range []struct {
+		class string
+		short string
+	}{
+		{"Blueprint'/Game/PrimalEarth/CoreBlueprints/Items/Armor/Riot/PrimalItemArmor_RiotHelmet.PrimalItemArmor_RiotHelmet'", "PrimalItemArmor_RiotHelmet"},
+		{"/Game/Mods/Foo/PrimalItem_Foo.PrimalItem_Foo_C", "PrimalItem_Foo_C"},
+		{"PrimalItemArmor_RiotHelmet_C", "PrimalItemArmor_RiotHelmet_C"},
+		{"", ""},
+	} {
+		assert.Equal(t, tc.short, ShortClass(tc.class), tc.class)
+	}
+}
+
+func TestReadPlayerFixture(t *testing.T) {
+	player, err := ReadPlayer("testdata/Players/76561198000000001.sav")
+	assert.Nil(t, err)
+
+	assert.Equal(t, ID("76561198000000001"), player.SteamId)
+	assert.Equal(t, "Bób <&>", player.Name())
+	assert.Equal(t, "Raptor Clan", player.Tribe())
+	assert.Equal(t, ID("1234567890"), player.Survivor.TribeId)
+	assert.Equal(t, 105, player.Survivor.Level)
+
+	if assert.Len(t, player.Items, 3) {
+		assert.Equal(t, &Item{
+			Id:       "1001",
+			Class:    "Blueprint'/Game/PrimalEarth/CoreBlueprints/Items/Armor/Riot/PrimalItemArmor_RiotHelmet.PrimalItemArmor_RiotHelmet'",
+			Name:     "Riot Helmet",
+			Quantity: 1,
+			Quality:  3.25,
+		}, player.Items[0])
+		assert.Equal(t, ID("1002"), player.Items[1].Id)
+		assert.Equal(t, 200, player.Items[1].Quantity)
+		assert.Equal(t, ID(""), player.Items[2].Id)
+		assert.True(t, player.Items[2].Blueprint)
+	}
+
+	if assert.Len(t, player.Dinos, 2) {
+		assert.Equal(t, &Dino{Id: "55501", Class: "Rex_Character_BP_C", Name: "Rexy 🦖", Level: 224, Gender: "Female"}, player.Dinos[0])
+		assert.Equal(t, ID("55502"), player.Dinos[1].Id)
+	}
+
+	// the fields the model does not know are kept in the data
+	assert.Contains(t, string(player.Data), `"UploadTime":"2026-10-01T12:00:00Z"`)
+	assert.Contains(t, string(player.Data), `"Colors":[1,2,3,4,5,6]`)
+
+	players, err := ReadPlayers("testdata")
+	assert.Nil(t, err)
+	assert.Equal(t, []*Player{player}, players)
+}
@@ -0,0 +1,277 @@
+package clustersav
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// snapshotLayout names the snapshot files, they sort by time
+const snapshotLayout = "20060102-150405"
+
+// Snapshot is a copy of the cluster data of every player at a time
+type Snapshot struct {
+	Time    time.Time         `json:"time"`
+	Players []*SnapshotPlayer `json:"players"`
+}
+
+// SnapshotPlayer is a Players/*.sav file of a Snapshot
+type SnapshotPlayer struct {
+	SteamId string `json:"steamid"`
+	File    string `json:"file"`
+	// Raw is the file as it was, written back byte for byte by a restore
+	Raw []byte `json:"sav"`
+}
+
+// TakeSnapshot copies the Players/*.sav files of the cluster dir
+func TakeSnapshot(clusterDir string) (snap *Snapshot, err error) {
+	matches, err := filepath.Glob(filepath.Join(clusterDir, "Players", "*.sav"))
+	if err != nil {
+		return nil, errors.Wrap(err, "filepath.Glob")
+	}
+
+	snap = &Snapshot{Time: time.Now().UTC(), Players: []*SnapshotPlayer{}}
+	for _, path := range matches {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "ioutil.ReadFile")
+		}
+
+		file := filepath.Base(path)
+		snapPlayer := &SnapshotPlayer{
+			SteamId: strings.TrimSuffix(file, filepath.Ext(file)),
+			File:    file,
+			Raw:     raw,
+		}
+
+		player, err := snapPlayer.Decode()
+		if err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+		snapPlayer.SteamId = string(player.SteamId)
+
+		snap.Players = append(snap.Players, snapPlayer)
+	}
+	return snap, nil
+}
+
+// Save writes the snapshot to dir as <time>.json and returns its path
+func (snap *Snapshot) Save(dir string) (path string, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "os.MkdirAll")
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return "", errors.Wrap(err, "json.Marshal")
+	}
+
+	path = filepath.Join(dir, snap.Time.Format(snapshotLayout)+".json")
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return "", errors.Wrap(err, "ioutil.WriteFile")
+	}
+	return path, nil
+}
+
+// LoadSnapshot reads a snapshot written by Save
+func LoadSnapshot(path string) (snap *Snapshot, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "ioutil.ReadFile")
+	}
+
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, errors.Wrapf(err, "json.Unmarshal(%v)", path)
+	}
+	return snap, nil
+}
+
+// Snapshots returns the snapshot files of dir, oldest first
+func Snapshots(dir string) (paths []string, err error) {
+	paths, err = filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.Wrap(err, "filepath.Glob")
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Player returns the snapshot of the player of steamId, nil when it has none
+func (snap *Snapshot) Player(steamId string) *SnapshotPlayer {
+	for _, player := range snap.Players {
+		if player.SteamId == steamId {
+			return player
+		}
+	}
+	return nil
+}
+
+// Decode decodes the data of the player
+func (player *SnapshotPlayer) Decode() (decoded *Player, err error) {
+	if len(player.Raw) == 0 {
+		return nil, errors.Errorf("no file of %v in the snapshot", player.SteamId)
+	}
+
+	sav, err := Parse(player.Raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parse")
+	}
+
+	decoded, err = Decode(sav.Data)
+	if err != nil {
+		return nil, err
+	}
+	if decoded.SteamId == "" {
+		decoded.SteamId = ID(player.SteamId)
+	}
+	return decoded, nil
+}
+
+// PlayerDiff is what changed in the cluster data of a player
+type PlayerDiff struct {
+	SteamId      string        `json:"steamid"`
+	Survivor     string        `json:"survivor"`
+	AddedItems   []*ItemChange `json:"added_items,omitempty"`
+	RemovedItems []*ItemChange `json:"removed_items,omitempty"`
+	AddedDinos   []*Dino       `json:"added_dinos,omitempty"`
+	RemovedDinos []*Dino       `json:"removed_dinos,omitempty"`
+}
+
+// ItemChange is an item added or removed, Quantity is how many
+type ItemChange struct {
+	Item     *Item `json:"item"`
+	Quantity int   `json:"quantity"`
+}
+
+// itemKey tells items apart, by the id the mod gives them when it does
+func itemKey(item *Item) string {
+	if item.Id != "" {
+		return "id:" + string(item.Id)
+	}
+	return fmt.Sprintf("%v|%v|%v|%v", item.Class, item.Name, item.Quality, item.Blueprint)
+}
+
+func dinoKey(dino *Dino) string {
+	if dino.Id != "" {
+		return "id:" + string(dino.Id)
+	}
+	return fmt.Sprintf("%v|%v|%v|%v", dino.Class, dino.Name, dino.Level, dino.Gender)
+}
+
+// Diff returns the items and dinos added and removed per player from a to b
+func Diff(a, b *Snapshot) (diffs []*PlayerDiff, err error) {
+	var steamIds []string
+	seen := map[string]bool{}
+	for _, snap := range []*Snapshot{a, b} {
+		for _, player := range snap.Players {
+			if !seen[player.SteamId] {
+				seen[player.SteamId] = true
+				steamIds = append(steamIds, player.SteamId)
+			}
+		}
+	}
+	sort.Strings(steamIds)
+
+	for _, steamId := range steamIds {
+		before, err := decodeOrEmpty(a.Player(steamId))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%v of %v", steamId, a.Time)
+		}
+		after, err := decodeOrEmpty(b.Player(steamId))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%v of %v", steamId, b.Time)
+		}
+
+		diff := DiffPlayer(before, after)
+		diff.SteamId = steamId
+
+		if !diff.Empty() {
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs, nil
+}
+
+func decodeOrEmpty(player *SnapshotPlayer) (*Player, error) {
+	if player == nil {
+		return &Player{}, nil
+	}
+	return player.Decode()
+}
+
+// Empty is whether nothing was added or removed
+func (diff *PlayerDiff) Empty() bool {
+	return len(diff.AddedItems)+len(diff.RemovedItems)+len(diff.AddedDinos)+len(diff.RemovedDinos) == 0
+}
+
+// DiffPlayer returns the items and dinos added and removed from before to
+// after, either may be an empty Player
+func DiffPlayer(before, after *Player) (diff *PlayerDiff) {
+	diff = &PlayerDiff{SteamId: string(after.SteamId), Survivor: after.Name()}
+	if diff.SteamId == "" {
+		diff.SteamId = string(before.SteamId)
+	}
+	if diff.Survivor == "" {
+		diff.Survivor = before.Name()
+	}
+
+	// quantities of the items by key, a stack that shrank is a removal
+	var keys []string
+	items := map[string]*Item{}
+	quantities := map[string]int{}
+	count := func(list []*Item, sign int) {
+		for _, item := range list {
+			key := itemKey(item)
+			if _, ok := items[key]; !ok {
+				keys = append(keys, key)
+				items[key] = item
+			}
+			quantity := item.Quantity
+			if quantity == 0 {
+				quantity = 1
+			}
+			quantities[key] += sign * quantity
+		}
+	}
+	count(before.Items, -1)
+	count(after.Items, 1)
+
+	for _, key := range keys {
+		switch n := quantities[key]; {
+		case n > 0:
+			diff.AddedItems = append(diff.AddedItems, &ItemChange{Item: items[key], Quantity: n})
+		case n < 0:
+			diff.RemovedItems = append(diff.RemovedItems, &ItemChange{Item: items[key], Quantity: -n})
+		}
+	}
+
+	dinos := map[string][]*Dino{}
+	for _, dino := range before.Dinos {
+		key := dinoKey(dino)
+		dinos[key] = append(dinos[key], dino)
+	}
+	for _, dino := range after.Dinos {
+		key := dinoKey(dino)
+		if len(dinos[key]) > 0 {
+			dinos[key] = dinos[key][1:]
+			continue
+		}
+		diff.AddedDinos = append(diff.AddedDinos, dino)
+	}
+	for _, dino := range before.Dinos {
+		key := dinoKey(dino)
+		if len(dinos[key]) > 0 && dinos[key][0] == dino {
+			diff.RemovedDinos = append(diff.RemovedDinos, dino)
+			dinos[key] = dinos[key][1:]
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,125 @@
+package clustersav
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffPlayer(t *testing.T) {
+	rex := func(name string, level int) *Dino {
+		return &Dino{Class: "Rex_Character_BP_C", Name: name, Level: level}
+	}
+	metal := func(quantity int) *Item {
+		return &Item{Class: "PrimalItemResource_MetalIngot_C", Name: "Metal Ingot", Quantity: quantity}
+	}
+	helmet := &Item{Id: "7", Class: "PrimalItemArmor_RiotHelmet_C", Name: "Riot Helmet", Quantity: 1, Quality: 2.5}
+
+	for _, tc := range []struct {
+		name         string
+		before       *Player
+		after        *Player
+		addedItems   map[string]int
+		removedItems map[string]int
+		addedDinos   []string
+		removedDinos []string
+	}{
+		{
+			name:   "unchanged",
+			before: &Player{Items: []*Item{metal(100), helmet}, Dinos: []*Dino{rex("Rex", 150)}},
+			after:  &Player{Items: []*Item{helmet, metal(100)}, Dinos: []*Dino{rex("Rex", 150)}},
+		},
+		{
+			name:         "stack shrank",
+			before:       &Player{Items: []*Item{metal(100)}},
+			after:        &Player{Items: []*Item{metal(40)}},
+			removedItems: map[string]int{"Metal Ingot": 60},
+		},
+		{
+			name:       "stack grew",
+			before:     &Player{Items: []*Item{metal(40)}},
+			after:      &Player{Items: []*Item{metal(100)}},
+			addedItems: map[string]int{"Metal Ingot": 60},
+		},
+		{
+			name:   "stacks split",
+			before: &Player{Items: []*Item{metal(100)}},
+			after:  &Player{Items: []*Item{metal(60), metal(40)}},
+		},
+		{
+			name:         "quantity 0 is 1",
+			before:       &Player{Items: []*Item{{Class: "PrimalItem_WeaponPike_C", Name: "Pike"}}},
+			after:        &Player{},
+			removedItems: map[string]int{"Pike": 1},
+		},
+		{
+			name:         "same class by id",
+			before:       &Player{Items: []*Item{helmet}},
+			after:        &Player{Items: []*Item{{Id: "8", Class: helmet.Class, Name: helmet.Name, Quantity: 1, Quality: 2.5}}},
+			addedItems:   map[string]int{"Riot Helmet": 1},
+			removedItems: map[string]int{"Riot Helmet": 1},
+		},
+		{
+			name:       "duplicate dino added",
+			before:     &Player{Dinos: []*Dino{rex("Rex", 150)}},
+			after:      &Player{Dinos: []*Dino{rex("Rex", 150), rex("Rex", 150)}},
+			addedDinos: []string{"Rex"},
+		},
+		{
+			name:         "duplicate dino removed",
+			before:       &Player{Dinos: []*Dino{rex("Rex", 150), rex("Other", 10), rex("Rex", 150)}},
+			after:        &Player{Dinos: []*Dino{rex("Rex", 150)}},
+			removedDinos: []string{"Other", "Rex"},
+		},
+		{
+			name:         "dino leveled",
+			before:       &Player{Dinos: []*Dino{rex("Rex", 150)}},
+			after:        &Player{Dinos: []*Dino{rex("Rex", 151)}},
+			addedDinos:   []string{"Rex"},
+			removedDinos: []string{"Rex"},
+		},
+		{
+			name:         "removed player",
+			before:       &Player{SteamId: "76561198000000000", Survivor: &Survivor{Name: "Bob"}, Items: []*Item{metal(10)}, Dinos: []*Dino{rex("Rex", 150)}},
+			after:        &Player{},
+			removedItems: map[string]int{"Metal Ingot": 10},
+			removedDinos: []string{"Rex"},
+		},
+	} {
+		diff := DiffPlayer(tc.before, tc.after)
+
+		items := func(changes []*ItemChange) map[string]int {
+			if len(changes) == 0 {
+				return nil
+			}
+			out := map[string]int{}
+			for _, change := range changes {
+				out[change.Item.Name] += change.Quantity
+			}
+			return out
+		}
+		dinos := func(list []*Dino) (names []string) {
+			for _, dino := range list {
+				names = append(names, dino.Name)
+			}
+			return names
+		}
+
+		assert.Equal(t, tc.addedItems, items(diff.AddedItems), tc.name)
+		assert.Equal(t, tc.removedItems, items(diff.RemovedItems), tc.name)
+		assert.Equal(t, tc.addedDinos, dinos(diff.AddedDinos), tc.name)
+		assert.Equal(t, tc.removedDinos, dinos(diff.RemovedDinos), tc.name)
+		assert.Equal(t, tc.addedItems == nil && tc.removedItems == nil && tc.addedDinos == nil && tc.removedDinos == nil, diff.Empty(), tc.name)
+	}
+}
+
+func TestDiffPlayerIdentity(t *testing.T) {
+	before := &Player{SteamId: "76561198000000000", Survivor: &Survivor{Name: "Bob"}}
+
+	diff := DiffPlayer(before, &Player{})
+	assert.Equal(t, "76561198000000000", diff.SteamId)
+	assert.Equal(t, "Bob", diff.Survivor)
+
+	diff = DiffPlayer(before, &Player{SteamId: "76561198000000000", Survivor: &Survivor{Name: "Bób"}})
+	assert.Equal(t, "Bób", diff.Survivor)
+}
@@ -0,0 +1,197 @@
+package clustersav
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// actions of an Event
+const (
+	// ActionUploaded is something added to the cluster data of a player
+	ActionUploaded = "uploaded"
+	// ActionDownloaded is something taken out of the cluster data
+	ActionDownloaded = "downloaded"
+)
+
+// kinds of an Event
+const (
+	KindSurvivor = "survivor"
+	KindItem     = "item"
+	KindDino     = "dino"
+)
+
+// watchDelay is how long a file must stay unchanged before it is read, the
+// mod may write it in several chunks
+const watchDelay = time.Second
+
+// Event is a transfer seen in the cluster data of a player
+type Event struct {
+	Time     time.Time `json:"time"`
+	SteamId  string    `json:"steamid"`
+	Survivor string    `json:"survivor"`
+	Action   string    `json:"action"`
+	Kind     string    `json:"kind"`
+	Class    string    `json:"class,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Quantity int       `json:"quantity,omitempty"`
+	Level    int       `json:"level,omitempty"`
+}
+
+// String is e.g. "Bob uploaded dino Chomper (Rex_Character_BP_C, level 250)"
+func (event *Event) String() string {
+	who := event.Survivor
+	if who == "" {
+		who = event.SteamId
+	}
+
+	switch event.Kind {
+	case KindSurvivor:
+		return fmt.Sprintf("%v %v survivor %v", who, event.Action, event.Name)
+	case KindDino:
+		return fmt.Sprintf("%v %v dino %v (%v, level %v)", who, event.Action, event.Name, ShortClass(event.Class), event.Level)
+	default:
+		return fmt.Sprintf("%v %v item %v x%v (%v)", who, event.Action, event.Name, event.Quantity, ShortClass(event.Class))
+	}
+}
+
+// Events returns the transfers from before to after
+func Events(before, after *Player) (events []*Event) {
+	now := time.Now()
+	diff := DiffPlayer(before, after)
+
+	event := func(action, kind string) *Event {
+		return &Event{Time: now, SteamId: diff.SteamId, Survivor: diff.Survivor, Action: action, Kind: kind}
+	}
+
+	switch {
+	case before.Survivor == nil && after.Survivor != nil:
+		e := event(ActionUploaded, KindSurvivor)
+		e.Name, e.Level = after.Survivor.Name, after.Survivor.Level
+		events = append(events, e)
+	case before.Survivor != nil && after.Survivor == nil:
+		e := event(ActionDownloaded, KindSurvivor)
+		e.Name, e.Level = before.Survivor.Name, before.Survivor.Level
+		events = append(events, e)
+	}
+
+	for _, changes := range []struct {
+		action string
+		items  []*ItemChange
+		dinos  []*Dino
+	}{
+		{ActionUploaded, diff.AddedItems, diff.AddedDinos},
+		{ActionDownloaded, diff.RemovedItems, diff.RemovedDinos},
+	} {
+		for _, change := range changes.items {
+			e := event(changes.action, KindItem)
+			e.Class, e.Name, e.Quantity = change.Item.Class, change.Item.Name, change.Quantity
+			events = append(events, e)
+		}
+		for _, dino := range changes.dinos {
+			e := event(changes.action, KindDino)
+			e.Class, e.Name, e.Level = dino.Class, dino.Name, dino.Level
+			events = append(events, e)
+		}
+	}
+
+	return events
+}
+
+// Watch calls emit with the transfers of the Players/*.sav files of the
+// cluster dir until ctx is done
+func Watch(ctx context.Context, clusterDir string, emit func(event *Event)) (err error) {
+	dir := filepath.Join(clusterDir, "Players")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "fsnotify.NewWatcher")
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		return errors.Wrapf(err, "watcher.Add(%v)", dir)
+	}
+
+	// the last known data of each file, the baseline of its changes
+	players := map[string]*Player{}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.sav"))
+	if err != nil {
+		return errors.Wrap(err, "filepath.Glob")
+	}
+	for _, path := range matches {
+		player, err := ReadPlayer(path)
+		if err != nil {
+			log.Warnf("ReadPlayer failure: %v", err)
+			continue
+		}
+		players[path] = player
+	}
+
+	log.Infof("watching %v players in %v", len(players), dir)
+
+	ticker := time.NewTicker(watchDelay / 4)
+	defer ticker.Stop()
+
+	pending := map[string]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Ext(ev.Name) == ".sav" {
+				pending[ev.Name] = time.Now()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return errors.Wrap(err, "watcher")
+		case now := <-ticker.C:
+			var paths []string
+			for path, changed := range pending {
+				if now.Sub(changed) >= watchDelay {
+					paths = append(paths, path)
+				}
+			}
+			sort.Strings(paths)
+
+			for _, path := range paths {
+				delete(pending, path)
+
+				before := players[path]
+				if before == nil {
+					before = &Player{}
+				}
+
+				after := &Player{}
+				if _, err := os.Stat(path); err == nil {
+					after, err = ReadPlayer(path)
+					if err != nil {
+						log.Warnf("ReadPlayer failure: %v", err)
+						continue
+					}
+					players[path] = after
+				} else {
+					// downloaded everything, or deleted
+					delete(players, path)
+				}
+
+				for _, event := range Events(before, after) {
+					emit(event)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/jeehoon/arktools/pkg/log"
+)
+
+type Owner struct {
+	Pid     int       `yaml:"pid"`
+	Command string    `yaml:"command"`
+	Started time.Time `yaml:"started"`
+}
+
+type LockedError struct {
+	Path  string
+	Owner *Owner
+}
+
+func (e *LockedError) Error() string {
+	if e.Owner == nil {
+		return fmt.Sprintf("%v is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%v is locked by pid %v (%v) since %v",
+		e.Path, e.Owner.Pid, e.Owner.Command, e.Owner.Started.Format(time.RFC3339))
+}
+
+type Lock struct {
+	path string
+	f    *os.File
+}
+
+// Acquire takes an exclusive flock on path and records the owner in it.
+// When wait is set it retries until the lock is free or ctx is done.
+func Acquire(ctx context.Context, path, command string, wait bool) (lock *Lock, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "os.OpenFile(%v)", path)
+	}
+
+	waiting := false
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+
+		if err != syscall.EWOULDBLOCK {
+			f.Close()
+			return nil, errors.Wrapf(err, "syscall.Flock(%v)", path)
+		}
+
+		locked := &LockedError{Path: path, Owner: readOwner(path)}
+		if !wait {
+			f.Close()
+			return nil, locked
+		}
+
+		if !waiting {
+			waiting = true
+			log.Infof("waiting: %v", locked)
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, errors.Wrap(ctx.Err(), locked.Error())
+		case <-time.After(time.Second):
+		}
+	}
+
+	owner := &Owner{
+		Pid:     os.Getpid(),
+		Command: command,
+		Started: time.Now(),
+	}
+
+	b, err := yaml.Marshal(owner)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "yaml.Marshal")
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "Truncate")
+	}
+
+	if _, err := f.WriteAt(b, 0); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "WriteAt")
+	}
+
+	return &Lock{path: path, f: f}, nil
+}
+
+// Release clears the owner and unlocks. The lock file is kept so that a
+// waiting process never locks a file that was already unlinked.
+func (lock *Lock) Release() (err error) {
+	defer lock.f.Close()
+
+	if err := lock.f.Truncate(0); err != nil {
+		return errors.Wrap(err, "Truncate")
+	}
+
+	if err := syscall.Flock(int(lock.f.Fd()), syscall.LOCK_UN); err != nil {
+		return errors.Wrapf(err, "syscall.Flock(%v)", lock.path)
+	}
+
+	return nil
+}
+
+func readOwner(path string) *Owner {
+	b, err := ioutil.ReadFile(path)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+
+	owner := new(Owner)
+	if err := yaml.Unmarshal(b, owner); err != nil {
+		return nil
+	}
+	return owner
+}
@@ -0,0 +1,164 @@
+package rcon
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// request types
+const (
+	TypeCommand = 2
+	TypeAuth    = 3
+)
+
+var ErrAuthFailed = errors.New("rcon authentication failed")
+
+type Header struct {
+	Len     uint32
+	Xid     uint32
+	ReqType uint32
+}
+
+type Client struct {
+	conn net.Conn
+	// authResp is the response body of the auth request
+	authResp string
+
+	mu  sync.Mutex
+	xid uint32
+}
+
+// Dial connects to the RCON port of the server and authenticates
+func Dial(ctx context.Context, addr, password string) (client *Client, err error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "net.Dial(%v)", addr)
+	}
+
+	client = &Client{
+		conn: conn,
+		xid:  100,
+	}
+
+	xid, resp, err := client.talk(ctx, TypeAuth, []byte(password))
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "sendMessage auth")
+	}
+
+	if xid == 0xffffffff {
+		conn.Close()
+		return nil, ErrAuthFailed
+	}
+	client.authResp = string(resp)
+
+	return client, nil
+}
+
+// AuthResponse is what the server answered to the password
+func (client *Client) AuthResponse() string {
+	return client.authResp
+}
+
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
+// Exec runs a console command and returns its output
+func (client *Client) Exec(ctx context.Context, command string) (resp string, err error) {
+	_, b, err := client.talk(ctx, TypeCommand, []byte(command))
+	if err != nil {
+		return "", errors.Wrap(err, "sendMessage command")
+	}
+	return string(b), nil
+}
+
+func (client *Client) talk(ctx context.Context, reqType uint32, payload []byte) (xid uint32, resp []byte, err error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		client.conn.SetDeadline(deadline)
+	} else {
+		client.conn.SetDeadline(time.Time{})
+	}
+
+	msg := &Header{}
+
+	// send
+	msg.Len = uint32(len(payload) + 10)
+	msg.Xid = client.xid
+	msg.ReqType = reqType
+	client.xid++
+
+	if err := binary.Write(client.conn, binary.LittleEndian, msg); err != nil {
+		return 0, nil, errors.Wrapf(err, "rcon write header")
+	}
+
+	body := append(payload, 0x00, 0x00)
+	if _, err := client.conn.Write(body); err != nil {
+		return 0, nil, errors.Wrapf(err, "rcon write body")
+	}
+
+	// recv
+	if err := binary.Read(client.conn, binary.LittleEndian, msg); err != nil {
+		return 0, nil, errors.Wrapf(err, "rcon read header")
+	}
+
+	if msg.Len < 10 {
+		return 0, nil, errors.Errorf("rcon invalid length: %v", msg.Len)
+	}
+
+	buff := make([]byte, msg.Len-8)
+	if _, err := io.ReadFull(client.conn, buff); err != nil {
+		return 0, nil, errors.Wrapf(err, "rcon read body")
+	}
+
+	buff = buff[:len(buff)-2]
+	return msg.Xid, buff, nil
+}
+
+/*
+class ArkRcon(object):
+  def __init__(self, ip, port, password):
+    self.sock = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
+    self.sock.connect((ip, port))
+    self.xid = 0
+    self.auth(password)
+
+  def send_message(self, reqtype, data):
+    self.xid += 1
+    msg = struct.pack('<III', len(data) + 10, self.xid, reqtype).decode('utf-8') + data + "\0\0";
+    b = msg.encode()
+    self.sock.send(b)
+    return self.xid
+
+  def recv_message(self):
+    b = self.sock.recv(12)
+    size, resid, restype =  struct.unpack('<III', b)
+    data = self.sock.recv(size)[:-2].decode('utf-8')
+    return resid, restype, data
+
+  def auth(self,password):
+    reqid = self.send_message(3, password)
+    resid, restype, data = self.recv_message()
+    if resid == -1 or resid == 0xffffffff:
+      raise Exception('ArkRcon', 'auth: Authentication failed')
+
+  def talk(self, message):
+    reqid = self.send_message(2, message)
+    while True:
+      resid, restype, data = self.recv_message()
+      if reqid == resid: break
+
+    print(data)
+
+
+*/
@@ -0,0 +1,174 @@
+package rcon
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	xid     uint32
+	reqType uint32
+	payload string
+}
+
+// fakeServer accepts one RCON connection and answers each request with
+// answer. It returns the address and the requests it received.
+func fakeServer(t *testing.T, answer func(conn net.Conn, req *request)) (addr string, requests chan *request) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	requests = make(chan *request, 16)
+	go func() {
+		defer close(requests)
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var header Header
+			if err := binary.Read(conn, binary.LittleEndian, &header); err != nil {
+				return
+			}
+			body := make([]byte, header.Len-8)
+			if _, err := io.ReadFull(conn, body); err != nil {
+				return
+			}
+
+			req := &request{xid: header.Xid, reqType: header.ReqType, payload: string(body[:len(body)-2])}
+			requests <- req
+			answer(conn, req)
+		}
+	}()
+
+	return ln.Addr().String(), requests
+}
+
+func reply(conn net.Conn, xid uint32, body string) {
+	binary.Write(conn, binary.LittleEndian, &Header{Len: uint32(len(body) + 10), Xid: xid, ReqType: 0})
+	conn.Write(append([]byte(body), 0, 0))
+}
+
+func TestClient(t *testing.T) {
+	addr, requests := fakeServer(t, func(conn net.Conn, req *request) {
+		switch req.reqType {
+		case TypeAuth:
+			reply(conn, req.xid, "")
+		case TypeCommand:
+			reply(conn, req.xid, "ran "+req.payload+" \n")
+		}
+	})
+
+	client, err := Dial(context.Background(), addr, "secret")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer client.Close()
+	assert.Equal(t, "", client.AuthResponse())
+
+	resp, err := client.Exec(context.Background(), "ListPlayers")
+	assert.Nil(t, err)
+	assert.Equal(t, "ran ListPlayers \n", resp)
+
+	resp, err = client.Exec(context.Background(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "ran  \n", resp)
+
+	client.Close()
+	assert.Equal(t, &request{xid: 100, reqType: TypeAuth, payload: "secret"}, <-requests)
+	assert.Equal(t, &request{xid: 101, reqType: TypeCommand, payload: "ListPlayers"}, <-requests)
+	assert.Equal(t, &request{xid: 102, reqType: TypeCommand, payload: ""}, <-requests)
+}
+
+func TestClientAuthFailed(t *testing.T) {
+	addr, _ := fakeServer(t, func(conn net.Conn, req *request) {
+		reply(conn, 0xffffffff, "")
+	})
+
+	_, err := Dial(context.Background(), addr, "wrong")
+	assert.Equal(t, ErrAuthFailed, err)
+}
+
+func TestClientInvalidLength(t *testing.T) {
+	addr, _ := fakeServer(t, func(conn net.Conn, req *request) {
+		binary.Write(conn, binary.LittleEndian, &Header{Len: 4, Xid: req.xid})
+	})
+
+	_, err := Dial(context.Background(), addr, "secret")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "invalid length")
+	}
+}
+
+func TestClientDeadline(t *testing.T) {
+	// the server authenticates and never answers a command
+	addr, _ := fakeServer(t, func(conn net.Conn, req *request) {
+		if req.reqType == TypeAuth {
+			reply(conn, req.xid, "")
+		}
+	})
+
+	client, err := Dial(context.Background(), addr, "secret")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err = client.Exec(ctx, "ListPlayers")
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+}
+
+func TestServerRun(t *testing.T) {
+	addr, requests := fakeServer(t, func(conn net.Conn, req *request) {
+		switch req.payload {
+		case "ListPlayers":
+			reply(conn, req.xid, "0. Bob, 76561198000000000 \n")
+		default:
+			reply(conn, req.xid, req.payload)
+		}
+	})
+
+	server := &Server{Name: "Island", RconAddr: addr, Password: "secret"}
+	out, err := server.Run(context.Background(), "a", "b")
+	assert.Nil(t, err)
+	assert.Equal(t, "a\nb", out)
+
+	// Run dials once for all the commands
+	var payloads []string
+	for i := 0; i < 3; i++ {
+		payloads = append(payloads, (<-requests).payload)
+	}
+	assert.Equal(t, []string{"secret", "a", "b"}, payloads)
+}
+
+func TestServerListPlayers(t *testing.T) {
+	addr, _ := fakeServer(t, func(conn net.Conn, req *request) {
+		reply(conn, req.xid, "0. Bob, 76561198000000000 \n")
+	})
+
+	server := &Server{Name: "Island", RconAddr: addr, Password: "secret"}
+	players, err := server.ListPlayers(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"76561198000000000": "Bob"}, players)
+
+	// nothing listening
+	server.RconAddr = "127.0.0.1:1"
+	_, err = server.ListPlayers(context.Background())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,62 @@
+package rcon
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// "0. Survivor Name, 76561198000000000"
+var patternPlayer = regexp.MustCompile(`^\d+\.\s*(.*),\s*(\d+)$`)
+
+// Server is a game server of the servers of the config
+type Server struct {
+	Name     string `mapstructure:"name"`
+	RconAddr string `mapstructure:"rcon-addr"`
+	Password string `mapstructure:"password"`
+}
+
+// Run runs the console commands on one connection and returns their outputs
+// joined by newlines
+func (server *Server) Run(ctx context.Context, commands ...string) (out string, err error) {
+	client, err := Dial(ctx, server.RconAddr, server.Password)
+	if err != nil {
+		return "", errors.Wrapf(err, "rcon.Dial(%v)", server.RconAddr)
+	}
+	defer client.Close()
+
+	var outs []string
+	for _, command := range commands {
+		resp, err := client.Exec(ctx, command)
+		if err != nil {
+			return "", errors.Wrapf(err, "client.Exec(%v)", command)
+		}
+		outs = append(outs, resp)
+	}
+
+	return strings.Join(outs, "\n"), nil
+}
+
+// ListPlayers returns the steam id => name of the players online
+func (server *Server) ListPlayers(ctx context.Context) (players map[string]string, err error) {
+	out, err := server.Run(ctx, "ListPlayers")
+	if err != nil {
+		return nil, err
+	}
+	return ParsePlayers(out), nil
+}
+
+// ParsePlayers returns the steam id => name of the players of a ListPlayers
+// output
+func ParsePlayers(out string) (players map[string]string) {
+	players = map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		match := patternPlayer.FindStringSubmatch(strings.TrimSpace(line))
+		if match != nil {
+			players[match[2]] = match[1]
+		}
+	}
+	return players
+}
@@ -0,0 +1,60 @@
+package rcon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlayers(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		out     string
+		players map[string]string
+	}{
+		{"empty", "", map[string]string{}},
+		{"blank", " \n\n", map[string]string{}},
+		{"nobody", "No Players Connected\n", map[string]string{}},
+		{"nobody, server", "No Players Connected \n", map[string]string{}},
+		{
+			name:    "one",
+			out:     "0. Bob, 76561198000000000 \n",
+			players: map[string]string{"76561198000000000": "Bob"},
+		},
+		{
+			name: "several",
+			out:  "\n0. Bob, 76561198000000000\n1. Alice Smith, 76561198000000001\n",
+			players: map[string]string{
+				"76561198000000000": "Bob",
+				"76561198000000001": "Alice Smith",
+			},
+		},
+		{
+			name:    "crlf",
+			out:     "0. Bob, 76561198000000000\r\n1. Alice, 76561198000000001\r\n",
+			players: map[string]string{"76561198000000000": "Bob", "76561198000000001": "Alice"},
+		},
+		{
+			name:    "comma in the name",
+			out:     "0. Bob, the Builder, 76561198000000000\n",
+			players: map[string]string{"76561198000000000": "Bob, the Builder"},
+		},
+		{
+			name:    "unicode and digits in the name",
+			out:     "12. Bób 2, 76561198000000000\n",
+			players: map[string]string{"76561198000000000": "Bób 2"},
+		},
+		{
+			name:    "empty name",
+			out:     "0. , 76561198000000000\n",
+			players: map[string]string{"76561198000000000": ""},
+		},
+		{
+			name:    "not a player",
+			out:     "Server received, But no response!! \n0. Bob\nBob, 76561198000000000\n",
+			players: map[string]string{},
+		},
+	} {
+		assert.Equal(t, tc.players, ParsePlayers(tc.out), tc.name)
+	}
+}
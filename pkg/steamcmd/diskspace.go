@@ -0,0 +1,208 @@
+package steamcmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/jeehoon/arktools/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// modUnpackRatio is the assumed ratio of unpacked to downloaded mod size.
+// unpackFile keeps the .z file until the unpacked copy is written, so a mod
+// needs its download size plus the unpacked size at peak.
+const modUnpackRatio = 3
+
+type InsufficientSpaceError struct {
+	Path      string
+	Required  uint64
+	Available uint64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("not enough disk space on %v: %v required, %v available (%v more needed)",
+		e.Path, HumanBytes(e.Required), HumanBytes(e.Available), HumanBytes(e.Required-e.Available))
+}
+
+func HumanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%v B", n)
+	}
+
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// freeSpace returns the bytes available to the user on the filesystem of
+// path, or of its nearest existing parent when path is not created yet
+func freeSpace(path string) (free uint64, err error) {
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return 0, errors.Wrap(err, "filepath.Abs")
+	}
+
+	for {
+		var stat syscall.Statfs_t
+		err := syscall.Statfs(path, &stat)
+		if err == nil {
+			return stat.Bavail * uint64(stat.Bsize), nil
+		}
+
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return 0, errors.Wrapf(err, "syscall.Statfs(%v)", path)
+		}
+		path = parent
+	}
+}
+
+func (steamcmd *SteamCmd) checkSpace(required uint64) (err error) {
+	available, err := freeSpace(steamcmd.installDir)
+	if err != nil {
+		return errors.Wrap(err, "freeSpace")
+	}
+
+	log.Debugf("Disk Space: required %v, available %v", HumanBytes(required), HumanBytes(available))
+
+	if required > available {
+		return &InsufficientSpaceError{
+			Path:      steamcmd.installDir,
+			Required:  required,
+			Available: available,
+		}
+	}
+
+	return nil
+}
+
+// CheckServerSpace estimates the space app_update needs from the depot sizes
+// in app_info and compares it with the free space of the install dir
+func (steamcmd *SteamCmd) CheckServerSpace(ctx context.Context, appId int) (err error) {
+	info, err := steamcmd.getAppInfo(ctx, appId)
+	if err != nil {
+		return errors.Wrapf(err, "steamcmd.getAppInfo(%v)", appId)
+	}
+
+	var size, download uint64
+	maxsizes := map[string]uint64{}
+	sizes := map[string]uint64{}
+	downloads := map[string]uint64{}
+
+	for _, pair := range ReadAcf(info) {
+		// .376030.depots.376031.maxsize 21474836480
+		// .376030.depots.376031.manifests.public.size 20379341870
+		// .376030.depots.376031.manifests.public.download 8123456789
+		arr := strings.Split(pair[0], ".")
+		if len(arr) < 5 || arr[2] != "depots" {
+			continue
+		}
+
+		depot := arr[3]
+		if _, err := strconv.Atoi(depot); err != nil {
+			continue
+		}
+
+		n, err := strconv.ParseUint(pair[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case len(arr) == 5 && arr[4] == "maxsize":
+			maxsizes[depot] = n
+		case len(arr) == 7 && arr[4] == "manifests" && arr[5] == "public" && arr[6] == "size":
+			sizes[depot] = n
+		case len(arr) == 7 && arr[4] == "manifests" && arr[5] == "public" && arr[6] == "download":
+			downloads[depot] = n
+		}
+	}
+
+	for depot, maxsize := range maxsizes {
+		if _, has := sizes[depot]; !has {
+			sizes[depot] = maxsize
+		}
+	}
+
+	for depot, n := range sizes {
+		size += n
+		// without a download size assume the whole depot is staged
+		if d, has := downloads[depot]; has {
+			download += d
+		} else {
+			download += n
+		}
+	}
+
+	if size == 0 {
+		log.Warnf("ARK Server size is unknown, skip disk space check")
+		return nil
+	}
+
+	// files already installed are replaced in place
+	installed, err := steamcmd.sizeOnDisk(appId)
+	if err != nil {
+		return errors.Wrap(err, "steamcmd.sizeOnDisk")
+	}
+
+	var required uint64
+	if size > installed {
+		required = size - installed
+	}
+	required += download
+
+	if err := steamcmd.checkSpace(required); err != nil {
+		return errors.Wrap(err, "ARK Server")
+	}
+
+	return nil
+}
+
+func (steamcmd *SteamCmd) sizeOnDisk(appId int) (size uint64, err error) {
+	localAcf := filepath.Join(steamcmd.installDir, "steamapps", fmt.Sprintf("appmanifest_%v.acf", appId))
+	b, err := ioutil.ReadFile(localAcf)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "ioutil.ReadFile(appmanifest)")
+	}
+
+	for _, pair := range ReadAcf(string(b)) {
+		if pair[0] == ".AppState.SizeOnDisk" {
+			size, _ = strconv.ParseUint(pair[1], 10, 64)
+		}
+	}
+
+	return size, nil
+}
+
+// CheckModsSpace estimates the space UpdateMods needs from the workshop file
+// sizes and compares it with the free space of the install dir
+func (steamcmd *SteamCmd) CheckModsSpace(ctx context.Context, modIds []int) (err error) {
+	var required uint64
+
+	for _, modId := range modIds {
+		item, err := steamcmd.getPublishedFileDetails(modId)
+		if err != nil {
+			return errors.Wrapf(err, "steamcmd.GetPublishedFileDetails(%v)", modId)
+		}
+		required += uint64(item.FileSize) * (1 + modUnpackRatio)
+	}
+
+	if err := steamcmd.checkSpace(required); err != nil {
+		return errors.Wrapf(err, "ARK MOD %v", modIds)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package steamcmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type ModDependencies struct {
+	// Order is the ActiveMods order followed by the missing dependencies
+	Order []int
+	// Requires maps modid to the workshop items it requires
+	Requires map[int][]int
+	// Missing maps modid to the required items which are not in ActiveMods
+	Missing map[int][]int
+	// Unresolved is whether the requirements are unknown, the workshop only
+	// reports them with a steam api key
+	Unresolved bool
+
+	roots  []int
+	titles map[int]string
+}
+
+func (steamcmd *SteamCmd) ResolveModDependencies(ctx context.Context, modIds []int) (deps *ModDependencies, err error) {
+	deps = &ModDependencies{
+		Requires: map[int][]int{},
+		Missing:  map[int][]int{},
+		roots:    modIds,
+		titles:   map[int]string{},
+	}
+
+	if steamcmd.apiKey == "" {
+		deps.Unresolved = true
+		steamcmd.SendUserf("! ARK MOD dependencies are not resolved (steam api key is not set)")
+	}
+
+	active := map[int]bool{}
+	for _, modId := range modIds {
+		active[modId] = true
+	}
+
+	visited := map[int]bool{}
+	queue := append([]int{}, modIds...)
+	deps.Order = append(deps.Order, modIds...)
+
+	for len(queue) > 0 {
+		modId := queue[0]
+		queue = queue[1:]
+
+		if visited[modId] {
+			continue
+		}
+		visited[modId] = true
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		item, err := steamcmd.getPublishedFileDetails(modId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "steamcmd.GetPublishedFileDetails(%v)", modId)
+		}
+		deps.titles[modId] = item.Title
+		deps.Requires[modId] = item.Children
+
+		for _, childId := range item.Children {
+			if !active[childId] {
+				deps.Missing[modId] = append(deps.Missing[modId], childId)
+				if !visited[childId] && !contains(deps.Order, childId) {
+					deps.Order = append(deps.Order, childId)
+				}
+			}
+			queue = append(queue, childId)
+		}
+	}
+
+	for _, modId := range deps.Order {
+		for _, childId := range deps.Missing[modId] {
+			steamcmd.SendUserf("! ARK MOD[%v](%v) requires MOD[%v](%v) which is missing from ActiveMods",
+				modId, deps.titles[modId], childId, deps.titles[childId])
+		}
+	}
+
+	return deps, nil
+}
+
+// ModNode is a mod of the dependency graph and the mods it requires
+type ModNode struct {
+	Id    int    `json:"id"`
+	Title string `json:"title"`
+	// Missing is whether the mod is missing from ActiveMods
+	Missing bool `json:"missing,omitempty"`
+	// Cycle is whether the mod requires itself through its parents, its
+	// requirements are not repeated
+	Cycle    bool       `json:"cycle,omitempty"`
+	Requires []*ModNode `json:"requires,omitempty"`
+}
+
+// Graph returns the dependency graph of the ActiveMods
+func (deps *ModDependencies) Graph() (roots []*ModNode) {
+	var walk func(parentId, modId int, path map[int]bool) *ModNode
+	walk = func(parentId, modId int, path map[int]bool) *ModNode {
+		node := &ModNode{
+			Id:      modId,
+			Title:   deps.titles[modId],
+			Missing: contains(deps.Missing[parentId], modId),
+		}
+
+		if path[modId] {
+			node.Cycle = true
+			return node
+		}
+
+		path[modId] = true
+		defer delete(path, modId)
+
+		for _, childId := range deps.Requires[modId] {
+			node.Requires = append(node.Requires, walk(modId, childId, path))
+		}
+		return node
+	}
+
+	for _, modId := range deps.roots {
+		roots = append(roots, walk(0, modId, map[int]bool{}))
+	}
+	return roots
+}
+
+func (steamcmd *SteamCmd) PrintModDependencies(deps *ModDependencies) {
+	var walk func(node *ModNode, depth int)
+	walk = func(node *ModNode, depth int) {
+		line := fmt.Sprintf("%vMOD[%v](%v)", strings.Repeat("  ", depth), node.Id, node.Title)
+		if node.Missing {
+			line += " (missing from ActiveMods)"
+		}
+		if node.Cycle {
+			line += " (cycle)"
+		}
+		steamcmd.SendUserf("%v", line)
+
+		for _, child := range node.Requires {
+			walk(child, depth+1)
+		}
+	}
+
+	if deps.Unresolved {
+		steamcmd.SendUserf("! ARK MOD dependency graph without requirements (steam api key is not set)")
+	}
+	for _, node := range deps.Graph() {
+		walk(node, 0)
+	}
+}
+
+func contains(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package steamcmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newModSteamCmd returns a SteamCmd with the workshop details of items
+// cached, no request is made
+func newModSteamCmd(items ...*WorkshopItem) *SteamCmd {
+	scmd := NewSteamCmd("steamcmd", "")
+	scmd.SetApiKey("key")
+	for _, item := range items {
+		scmd.workshopItems[item.Id] = item
+	}
+	return scmd
+}
+
+func TestResolveModDependencies(t *testing.T) {
+	// 1 requires 3 and 2, 3 requires 4 and 1 again
+	scmd := newModSteamCmd(
+		&WorkshopItem{Id: 1, Title: "one", Children: []int{3, 2}},
+		&WorkshopItem{Id: 2, Title: "two"},
+		&WorkshopItem{Id: 3, Title: "three", Children: []int{4, 1}},
+		&WorkshopItem{Id: 4, Title: "four"},
+	)
+	out := new(bytes.Buffer)
+	scmd.SetOutput(out)
+
+	deps, err := scmd.ResolveModDependencies(context.Background(), []int{1, 2})
+	assert.Nil(t, err)
+	assert.False(t, deps.Unresolved)
+	assert.Equal(t, []int{1, 2, 3, 4}, deps.Order)
+	assert.Equal(t, map[int][]int{1: {3}, 3: {4}}, deps.Missing)
+	assert.Equal(t, map[int][]int{1: {3, 2}, 2: nil, 3: {4, 1}, 4: nil}, deps.Requires)
+
+	assert.Equal(t, []*ModNode{
+		{Id: 1, Title: "one", Requires: []*ModNode{
+			{Id: 3, Title: "three", Missing: true, Requires: []*ModNode{
+				{Id: 4, Title: "four", Missing: true},
+				{Id: 1, Title: "one", Cycle: true},
+			}},
+			{Id: 2, Title: "two"},
+		}},
+		{Id: 2, Title: "two"},
+	}, deps.Graph())
+
+	out.Reset()
+	scmd.PrintModDependencies(deps)
+	assert.Equal(t, ""+
+		"MOD[1](one)\n"+
+		"  MOD[3](three) (missing from ActiveMods)\n"+
+		"    MOD[4](four) (missing from ActiveMods)\n"+
+		"    MOD[1](one) (cycle)\n"+
+		"  MOD[2](two)\n"+
+		"MOD[2](two)\n", out.String())
+}
+
+func TestResolveModDependenciesOrder(t *testing.T) {
+	// a mod required by several is added once, after the ActiveMods
+	scmd := newModSteamCmd(
+		&WorkshopItem{Id: 5, Children: []int{9, 7}},
+		&WorkshopItem{Id: 6, Children: []int{7, 8}},
+		&WorkshopItem{Id: 7, Children: []int{9}},
+		&WorkshopItem{Id: 8},
+		&WorkshopItem{Id: 9, Children: []int{5}},
+	)
+
+	deps, err := scmd.ResolveModDependencies(context.Background(), []int{6, 5})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{6, 5, 7, 8, 9}, deps.Order)
+	assert.Equal(t, map[int][]int{5: {9, 7}, 6: {7, 8}, 7: {9}}, deps.Missing)
+
+	// ActiveMods without requirements are kept as given
+	deps, err = scmd.ResolveModDependencies(context.Background(), []int{8})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{8}, deps.Order)
+	assert.Empty(t, deps.Missing)
+}
+
+func TestResolveModDependenciesUnresolved(t *testing.T) {
+	scmd := newModSteamCmd(&WorkshopItem{Id: 1, Title: "one"})
+	scmd.SetApiKey("")
+	out := new(bytes.Buffer)
+	scmd.SetOutput(out)
+
+	deps, err := scmd.ResolveModDependencies(context.Background(), []int{1})
+	assert.Nil(t, err)
+	assert.True(t, deps.Unresolved)
+	assert.Equal(t, []int{1}, deps.Order)
+	assert.Contains(t, out.String(), "not resolved")
+
+	out.Reset()
+	scmd.PrintModDependencies(deps)
+	assert.Equal(t, ""+
+		"! ARK MOD dependency graph without requirements (steam api key is not set)\n"+
+		"MOD[1](one)\n", out.String())
+}
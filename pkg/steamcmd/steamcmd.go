@@ -30,13 +30,19 @@ import (
 type SteamCmd struct {
 	exec       string
 	installDir string
+	apiKey     string
 	output     io.Writer
+
+	appInfos      map[int]string
+	workshopItems map[int]*WorkshopItem
 }
 
 func NewSteamCmd(exec, installDir string) *SteamCmd {
 	return &SteamCmd{
-		exec:       exec,
-		installDir: installDir,
+		exec:          exec,
+		installDir:    installDir,
+		appInfos:      map[int]string{},
+		workshopItems: map[int]*WorkshopItem{},
 	}
 }
 
@@ -44,6 +50,12 @@ func (steamcmd *SteamCmd) SetOutput(w io.Writer) {
 	steamcmd.output = w
 }
 
+// SetApiKey sets the Steam Web API key. Workshop dependencies can only be
+// resolved when a key is set.
+func (steamcmd *SteamCmd) SetApiKey(key string) {
+	steamcmd.apiKey = key
+}
+
 func (steamcmd *SteamCmd) SendUserf(f string, args ...any) {
 	if steamcmd.output == nil {
 		return
@@ -148,6 +160,10 @@ func (steamcmd *SteamCmd) Run(ctx context.Context, fn func(out string) (cmd stri
 }
 
 func (steamcmd *SteamCmd) getAppInfo(ctx context.Context, appId int) (info string, err error) {
+	if info, has := steamcmd.appInfos[appId]; has {
+		return info, nil
+	}
+
 	bLogon := false
 	bAppInfoUpdate := false
 	bAppInfoPrint := false
@@ -188,6 +204,7 @@ func (steamcmd *SteamCmd) getAppInfo(ctx context.Context, appId int) (info strin
 		return "", errors.Wrap(err, "steamcmd.Run")
 	}
 
+	steamcmd.appInfos[appId] = info
 	return info, nil
 }
 
@@ -226,23 +243,45 @@ func (steamcmd *SteamCmd) UpdateServer(ctx context.Context, appId int) (err erro
 	return nil
 }
 
-func (steamcmd *SteamCmd) HasUpdate(ctx context.Context, appId int) (hasUpdate bool, err error) {
+type ServerStatus struct {
+	AppId        int
+	Installed    bool
+	LocalBuild   string
+	RemoteBuild  string
+	LocalDepots  map[string]string
+	RemoteDepots map[string]string
+	HasUpdate    bool
+}
+
+func (steamcmd *SteamCmd) ServerStatus(ctx context.Context, appId int) (status *ServerStatus, err error) {
+	status = &ServerStatus{
+		AppId:        appId,
+		LocalDepots:  map[string]string{},
+		RemoteDepots: map[string]string{},
+	}
+
 	// read steam app info
 	info, err := steamcmd.getAppInfo(ctx, appId)
 	if err != nil {
-		return false, errors.Wrapf(err, "steamcmd.getAppInfo(%v)", appId)
+		return nil, errors.Wrapf(err, "steamcmd.getAppInfo(%v)", appId)
 	}
 
-	steamInfo := map[string]string{}
 	steamPairs := ReadAcf(info)
 	for _, pair := range steamPairs {
 		// .376030.depots.1004.manifests.public 4660701598619066954
+		// .376030.depots.1004.manifests.public.gid 4660701598619066954
+		// .376030.depots.branches.public.buildid 11069521
 		arr := strings.Split(pair[0], ".")
-		if arr[2] == "depots" && arr[4] == "manifests" && arr[5] == "public" {
-			steamInfo[arr[3]] = pair[1]
+		switch {
+		case len(arr) == 6 && arr[2] == "depots" && arr[3] == "branches" && arr[4] == "public" && arr[5] == "buildid":
+			status.RemoteBuild = pair[1]
+		case len(arr) == 6 && arr[2] == "depots" && arr[4] == "manifests" && arr[5] == "public":
+			status.RemoteDepots[arr[3]] = pair[1]
+		case len(arr) == 7 && arr[2] == "depots" && arr[4] == "manifests" && arr[5] == "public" && arr[6] == "gid":
+			status.RemoteDepots[arr[3]] = pair[1]
 		}
 	}
-	log.Debugf("Steam Depots: %q", steamInfo)
+	log.Debugf("Steam Depots: %q", status.RemoteDepots)
 
 	// read local app info
 	localAcf := filepath.Join(steamcmd.installDir, "steamapps", fmt.Sprintf("appmanifest_%v.acf", appId))
@@ -250,83 +289,127 @@ func (steamcmd *SteamCmd) HasUpdate(ctx context.Context, appId int) (hasUpdate b
 	if err != nil {
 		if os.IsNotExist(err) {
 			steamcmd.SendUserf("+ ARK Server is not installed")
-			return true, nil
+			status.HasUpdate = true
+			return status, nil
 		}
-		return false, errors.Wrap(err, "ioutil.ReadFile(appmanifest)")
+		return nil, errors.Wrap(err, "ioutil.ReadFile(appmanifest)")
 	}
+	status.Installed = true
 
-	localInfo := map[string]string{}
 	localPairs := ReadAcf(string(b))
 	for _, pair := range localPairs {
 		// .AppState.InstalledDepots.1006.manifest" "6912453647411644579"
+		// .AppState.buildid 11069521
 		arr := strings.Split(pair[0], ".")
-		if arr[1] == "AppState" && arr[2] == "InstalledDepots" && arr[4] == "manifest" {
-			localInfo[arr[3]] = pair[1]
+		switch {
+		case len(arr) == 3 && arr[1] == "AppState" && arr[2] == "buildid":
+			status.LocalBuild = pair[1]
+		case len(arr) == 5 && arr[1] == "AppState" && arr[2] == "InstalledDepots" && arr[4] == "manifest":
+			status.LocalDepots[arr[3]] = pair[1]
 		}
 	}
-	log.Debugf("Local Depots: %q", localInfo)
+	log.Debugf("Local Depots: %q", status.LocalDepots)
 
 	// compare
-	for k, _ := range localInfo {
-		if steamInfo[k] != localInfo[k] {
-			hasUpdate = true
+	for k, _ := range status.LocalDepots {
+		if status.RemoteDepots[k] != status.LocalDepots[k] {
+			status.HasUpdate = true
 			break
 		}
 	}
 
-	if hasUpdate {
+	if status.HasUpdate {
 		steamcmd.SendUserf("+ ARK Server update required")
 	} else {
 		steamcmd.SendUserf(": ARK Server is up-to-date")
 	}
 
-	return hasUpdate, nil
+	return status, nil
 }
 
-func (steamcmd *SteamCmd) UpdateRequiredMods(ctx context.Context, appId int, modIds []int) (required []int, err error) {
+func (steamcmd *SteamCmd) HasUpdate(ctx context.Context, appId int) (hasUpdate bool, err error) {
+	status, err := steamcmd.ServerStatus(ctx, appId)
+	if err != nil {
+		return false, errors.Wrapf(err, "steamcmd.ServerStatus(%v)", appId)
+	}
+	return status.HasUpdate, nil
+}
+
+type ModStatus struct {
+	ModId     int
+	Title     string
+	Local     int
+	Remote    int
+	HasUpdate bool
+}
+
+func (steamcmd *SteamCmd) ModsStatus(ctx context.Context, appId int, modIds []int) (statuses []*ModStatus, err error) {
 
 	for _, modId := range modIds {
 		// fetch from steam
-		title, steamUpdated, err := steamcmd.getPublishedFileDetails(modId)
+		item, err := steamcmd.getPublishedFileDetails(modId)
 		if err != nil {
 			return nil, errors.Wrapf(err, "steamcmd.GetPublishedFileDetails(%v)", modId)
 		}
 
+		status := &ModStatus{
+			ModId:  modId,
+			Title:  item.Title,
+			Remote: item.Updated,
+		}
+
 		//fetch from local
 		yamlFile := filepath.Join(steamcmd.modsRoot(), fmt.Sprintf("%v.yaml", modId))
-		_, localUpdated, err := steamcmd.readYaml(yamlFile)
+		modInfo, err := steamcmd.readYaml(yamlFile)
 		if os.IsNotExist(err) {
 			log.Warnf("MOD[%v] is not exist yaml file", modId)
-			localUpdated = 0
 		} else if err != nil {
 			return nil, errors.Wrapf(err, "open mod .yaml")
+		} else {
+			status.Local = modInfo.Updated
 		}
 
-		if steamUpdated == localUpdated {
-			log.Infof("MOD[%v](%v) is up-to-date.", modId, title)
-			steamcmd.SendUserf(": ARK MOD[%v](%v) is up-to-date", modId, title)
+		if status.Remote == status.Local {
+			log.Infof("MOD[%v](%v) is up-to-date.", modId, status.Title)
+			steamcmd.SendUserf(": ARK MOD[%v](%v) is up-to-date", modId, status.Title)
 		} else {
-			required = append(required, modId)
-			log.Infof("MOD[%v](%v) is update required.", modId, title)
-			steamcmd.SendUserf("+ ARK MOD[%v](%v) update required", modId, title)
+			status.HasUpdate = true
+			log.Infof("MOD[%v](%v) is update required.", modId, status.Title)
+			steamcmd.SendUserf("+ ARK MOD[%v](%v) update required", modId, status.Title)
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (steamcmd *SteamCmd) UpdateRequiredMods(ctx context.Context, appId int, modIds []int) (required []int, err error) {
+	statuses, err := steamcmd.ModsStatus(ctx, appId, modIds)
+	if err != nil {
+		return nil, errors.Wrapf(err, "steamcmd.ModsStatus(%v)", appId)
+	}
+
+	for _, status := range statuses {
+		if status.HasUpdate {
+			required = append(required, status.ModId)
 		}
 	}
 
 	return required, nil
 }
 
-func (steamcmd *SteamCmd) UpdateMods(ctx context.Context, appId int, modIds []int) (err error) {
+func (steamcmd *SteamCmd) UpdateMods(ctx context.Context, appId int, modIds []int) (updated []int, err error) {
 	// download Mods
 	bInstallDir := false
 	bLogon := false
 
 	modTitles := map[int]string{}
 	for _, modId := range modIds {
-		modTitle, _, err := steamcmd.getPublishedFileDetails(modId)
+		item, err := steamcmd.getPublishedFileDetails(modId)
 		if err != nil {
-			return errors.Wrapf(err, "steamcmd.GetPublishedFileDetails(%v)", modId)
+			return nil, errors.Wrapf(err, "steamcmd.GetPublishedFileDetails(%v)", modId)
 		}
-		modTitles[modId] = modTitle
+		modTitles[modId] = item.Title
 	}
 
 	var downloadIds []int
@@ -355,30 +438,38 @@ func (steamcmd *SteamCmd) UpdateMods(ctx context.Context, appId int, modIds []in
 			return "quit"
 		}
 	}); err != nil {
-		return errors.Wrap(err, "steamcmd.Run")
+		return nil, errors.Wrap(err, "steamcmd.Run")
 	}
 
 	// unpack & install
 	for _, modId := range downloadIds {
 		modTitle := modTitles[modId]
+
+		// the sizes as downloaded, before an unpack can go wrong
+		modFiles, err := workshopFiles(steamcmd.modPath(appId, modId))
+		if err != nil {
+			return updated, errors.Wrapf(err, "workshopFiles(%v)", modId)
+		}
+
 		log.Infof("MOD[%v](%v) unpack", modId, modTitle)
 		if err := steamcmd.unpackMod(ctx, appId, modId); err != nil {
-			return errors.Wrapf(err, "steamcmd.unpackMod(%v)", modId)
+			return updated, errors.Wrapf(err, "steamcmd.unpackMod(%v)", modId)
 		}
 
 		log.Infof("MOD[%v](%v) create .mod", modId, modTitle)
-		if err := steamcmd.createDotMod(ctx, appId, modId, modTitle); err != nil {
-			return errors.Wrapf(err, "steamcmd.createDotMod(%v)", modId)
+		if err := steamcmd.createDotMod(ctx, appId, modId, modTitle, modFiles); err != nil {
+			return updated, errors.Wrapf(err, "steamcmd.createDotMod(%v)", modId)
 		}
 
 		log.Infof("MOD[%v](%v) install", modId, modTitle)
 		if err := steamcmd.installMod(ctx, appId, modId); err != nil {
-			return errors.Wrapf(err, "steamcmd.installMod(%v)", modId)
+			return updated, errors.Wrapf(err, "steamcmd.installMod(%v)", modId)
 		}
 		steamcmd.SendUserf("+ ARK MOD[%v](%v) was updated (restart required)", modId, modTitle)
+		updated = append(updated, modId)
 	}
 
-	return nil
+	return updated, nil
 }
 
 func (steamcmd *SteamCmd) modPath(appId, modId int) string {
@@ -563,41 +654,86 @@ func writeUe4String(w io.Writer, s string) (err error) {
 	return nil
 }
 
-func (steamcmd *SteamCmd) getPublishedFileDetails(modId int) (title string, updated int, err error) {
-	resp, err := http.PostForm("http://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1",
-		url.Values{"itemcount": {"1"}, "publishedfileids[0]": {fmt.Sprintf("%v", modId)}})
+type WorkshopItem struct {
+	Id       int
+	Title    string
+	Updated  int
+	FileSize int64
+	Children []int
+}
+
+func (steamcmd *SteamCmd) getPublishedFileDetails(modId int) (item *WorkshopItem, err error) {
+	if item, has := steamcmd.workshopItems[modId]; has {
+		return item, nil
+	}
+
+	var resp *http.Response
+	if steamcmd.apiKey != "" {
+		// IPublishedFileService reports required items as children, but only with an api key
+		resp, err = http.Get("https://api.steampowered.com/IPublishedFileService/GetDetails/v1/?" + url.Values{
+			"key":                 {steamcmd.apiKey},
+			"includechildren":     {"true"},
+			"publishedfileids[0]": {fmt.Sprintf("%v", modId)},
+		}.Encode())
+	} else {
+		resp, err = http.PostForm("http://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1",
+			url.Values{"itemcount": {"1"}, "publishedfileids[0]": {fmt.Sprintf("%v", modId)}})
+	}
 	if err != nil {
-		return "", 0, errors.Wrapf(err, "http.PostForm")
+		return nil, errors.Wrapf(err, "http.Get")
 	}
+	defer resp.Body.Close()
 
 	var v = &struct {
 		Response struct {
 			PublishedFileDetails []struct {
-				Result      int    `json:"result"`
-				Title       string `json:"title"`
-				TimeUpdated int    `json:"time_updated"`
+				Result      int         `json:"result"`
+				Title       string      `json:"title"`
+				TimeUpdated int         `json:"time_updated"`
+				FileSize    json.Number `json:"file_size"`
+				Children    []struct {
+					PublishedFileId json.Number `json:"publishedfileid"`
+				} `json:"children"`
 			} `json:"publishedfiledetails"`
 		} `json:"response"`
 	}{}
 
 	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
-		return "", 0, errors.Wrapf(err, "json.Decode")
+		return nil, errors.Wrapf(err, "json.Decode")
 	}
-	resp.Body.Close()
 
 	if len(v.Response.PublishedFileDetails) == 0 {
-		return "", 0, errors.Errorf("get publish file detail failure: length 0")
+		return nil, errors.Errorf("get publish file detail failure: length 0")
 	}
 
-	if result := v.Response.PublishedFileDetails[0].Result; result != 1 {
-		return "", 0, errors.Errorf("get publish file detail failure: result: %v", result)
+	details := v.Response.PublishedFileDetails[0]
+	if details.Result != 1 {
+		return nil, errors.Errorf("get publish file detail failure: result: %v", details.Result)
 	}
 
-	modTitle := v.Response.PublishedFileDetails[0].Title
-	modUpdated := v.Response.PublishedFileDetails[0].TimeUpdated
-	log.Debugf("MOD[%v](%v) GetPublishedFileDetails updated:%v", modId, modTitle, modUpdated)
+	item = &WorkshopItem{
+		Id:      modId,
+		Title:   details.Title,
+		Updated: details.TimeUpdated,
+	}
+
+	if details.FileSize != "" {
+		if item.FileSize, err = details.FileSize.Int64(); err != nil {
+			return nil, errors.Wrapf(err, "parse file_size(%v)", details.FileSize)
+		}
+	}
+
+	for _, child := range details.Children {
+		childId, err := child.PublishedFileId.Int64()
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse publishedfileid(%v)", child.PublishedFileId)
+		}
+		item.Children = append(item.Children, int(childId))
+	}
+	log.Debugf("MOD[%v](%v) GetPublishedFileDetails updated:%v children:%v", modId, item.Title, item.Updated, item.Children)
 
-	return modTitle, modUpdated, nil
+	steamcmd.workshopItems[modId] = item
+	return item, nil
 }
 
 func (steamcmd *SteamCmd) readUpdatedFromAcf(appId, modId int) (updated int, err error) {
@@ -630,7 +766,7 @@ func (steamcmd *SteamCmd) readUpdatedFromAcf(appId, modId int) (updated int, err
 	return updated, nil
 }
 
-func (steamcmd *SteamCmd) createDotMod(ctx context.Context, appId, modId int, modTitle string) (err error) {
+func (steamcmd *SteamCmd) createDotMod(ctx context.Context, appId, modId int, modTitle string, modFiles map[string]int64) (err error) {
 	modPath := steamcmd.modPath(appId, modId)
 	modInfoPath := filepath.Join(modPath, "mod.info")
 	modmetaInfoPath := filepath.Join(modPath, "modmeta.info")
@@ -789,7 +925,11 @@ func (steamcmd *SteamCmd) createDotMod(ctx context.Context, appId, modId int, mo
 	}
 
 	// write .yaml
-	if err := steamcmd.writeYaml(yamlPath, modTitle, modUpdated); err != nil {
+	if err := steamcmd.writeYaml(yamlPath, &ModInfo{
+		Title:   modTitle,
+		Updated: modUpdated,
+		Files:   modFiles,
+	}); err != nil {
 		return errors.Wrap(err, "steamcmd.writeYaml")
 	}
 
@@ -799,20 +939,20 @@ func (steamcmd *SteamCmd) createDotMod(ctx context.Context, appId, modId int, mo
 type ModInfo struct {
 	Title   string `yaml:"title"`
 	Updated int    `yaml:"updated"`
+
+	// Files is the size of each file of the workshop download, unpacked,
+	// relative to the mod dir. steamcmd checked the download against the
+	// depot manifest.
+	Files map[string]int64 `yaml:"files,omitempty"`
 }
 
-func (steamcmd *SteamCmd) writeYaml(filepath string, title string, updated int) (err error) {
+func (steamcmd *SteamCmd) writeYaml(filepath string, modInfo *ModInfo) (err error) {
 	f, err := os.Create(filepath)
 	if err != nil {
 		return errors.Wrapf(err, "os.Create(%v)", filepath)
 	}
 	defer f.Close()
 
-	modInfo := &ModInfo{
-		Title:   title,
-		Updated: updated,
-	}
-
 	if err := yaml.NewEncoder(f).Encode(modInfo); err != nil {
 		return errors.Wrap(err, "write yaml file")
 	}
@@ -820,24 +960,83 @@ func (steamcmd *SteamCmd) writeYaml(filepath string, title string, updated int)
 	return nil
 }
 
-func (steamcmd *SteamCmd) readYaml(filepath string) (title string, updated int, err error) {
+func (steamcmd *SteamCmd) readYaml(filepath string) (modInfo *ModInfo, err error) {
 	f, err := os.Open(filepath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", 0, err
+			return nil, err
 		}
-		return "", 0, errors.Wrapf(err, "os.Open(%v)", filepath)
+		return nil, errors.Wrapf(err, "os.Open(%v)", filepath)
 	}
 	defer f.Close()
 
-	var modInfo = new(ModInfo)
+	modInfo = new(ModInfo)
 
 	if err := yaml.NewDecoder(f).Decode(modInfo); err != nil {
-		return "", 0, errors.Wrap(err, "read yaml file")
+		return nil, errors.Wrap(err, "read yaml file")
+	}
+
+	return modInfo, nil
+
+}
+
+// listModFiles returns the size of every file under modPath
+func listModFiles(modPath string) (files map[string]int64, err error) {
+	files = map[string]int64{}
+
+	if err := filepath.Walk(modPath, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(modPath, path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)] = info.Size()
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "filepath.Walk(%v)", modPath)
 	}
 
-	return modInfo.Title, modInfo.Updated, nil
+	return files, nil
+}
 
+// workshopFiles returns the size of every file of a workshop download once
+// unpacked: a compressed X.z is X of the size of its X.z.uncompressed_size
+func workshopFiles(modPath string) (files map[string]int64, err error) {
+	downloaded, err := listModFiles(modPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files = map[string]int64{}
+	for rel, size := range downloaded {
+		switch {
+		case strings.HasSuffix(rel, ".z"):
+		case strings.HasSuffix(rel, ".z.uncompressed_size"):
+			sizePath := filepath.Join(modPath, filepath.FromSlash(rel))
+			b, err := ioutil.ReadFile(sizePath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "ioutil.ReadFile(%v)", sizePath)
+			}
+
+			s := strings.TrimSpace(string(b))
+			unpacked, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "strconv.ParseInt(%v)", s)
+			}
+			files[strings.TrimSuffix(rel, ".z.uncompressed_size")] = unpacked
+		default:
+			files[rel] = size
+		}
+	}
+	return files, nil
 }
 
 func (steamcmd *SteamCmd) modsRoot() string {
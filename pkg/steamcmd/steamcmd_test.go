@@ -2,6 +2,10 @@ package steamcmd
 
 import (
 	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +21,91 @@ func TestUe4String(t *testing.T) {
 	assert.Equal(t, s, "hello!!!")
 	assert.Equal(t, nread, 12)
 }
+
+func TestServerStatus(t *testing.T) {
+	installDir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(installDir, "steamapps"), 0755))
+
+	scmd := NewSteamCmd("steamcmd", installDir)
+	// app_info_print, with keys too short for any of the cases
+	scmd.appInfos[376030] = strings.Join([]string{
+		`"376030"`,
+		`{`,
+		"\t\"depots\"\t\"1\"",
+		"\t\"depots\"",
+		`	{`,
+		"\t\t\"1004\"\t\"2\"",
+		"\t\t\"1004\"",
+		`		{`,
+		"\t\t\t\"manifests\"\t\"3\"",
+		"\t\t\t\"manifests\"",
+		`			{`,
+		"\t\t\t\t\"public\"\t\"4660701598619066954\"",
+		`			}`,
+		`		}`,
+		"\t\t\"1006\"",
+		`		{`,
+		"\t\t\t\"manifests\"",
+		`			{`,
+		"\t\t\t\t\"public\"",
+		`				{`,
+		"\t\t\t\t\t\"gid\"\t\"6912453647411644579\"",
+		`				}`,
+		`			}`,
+		`		}`,
+		"\t\t\"branches\"",
+		`		{`,
+		"\t\t\t\"public\"",
+		`			{`,
+		"\t\t\t\t\"buildid\"\t\"11069521\"",
+		`			}`,
+		`		}`,
+		`	}`,
+		`}`,
+	}, "\n")
+
+	// not installed
+	status, err := scmd.ServerStatus(context.Background(), 376030)
+	assert.Nil(t, err)
+	assert.False(t, status.Installed)
+	assert.True(t, status.HasUpdate)
+	assert.Equal(t, "11069521", status.RemoteBuild)
+	assert.Equal(t, map[string]string{"1004": "4660701598619066954", "1006": "6912453647411644579"}, status.RemoteDepots)
+
+	manifest := func(depot1006 string) string {
+		return strings.Join([]string{
+			"\"buildid\"\t\"1\"",
+			`"AppState"`,
+			`{`,
+			"\t\"buildid\"\t\"11069520\"",
+			"\t\"InstalledDepots\"\t\"x\"",
+			"\t\"InstalledDepots\"",
+			`	{`,
+			"\t\t\"1004\"\t\"y\"",
+			"\t\t\"1004\"",
+			`		{`,
+			"\t\t\t\"manifest\"\t\"4660701598619066954\"",
+			`		}`,
+			"\t\t\"1006\"",
+			`		{`,
+			"\t\t\t\"manifest\"\t\"" + depot1006 + "\"",
+			`		}`,
+			`	}`,
+			`}`,
+		}, "\n")
+	}
+	acf := filepath.Join(installDir, "steamapps", "appmanifest_376030.acf")
+
+	assert.Nil(t, os.WriteFile(acf, []byte(manifest("6912453647411644579")), 0644))
+	status, err = scmd.ServerStatus(context.Background(), 376030)
+	assert.Nil(t, err)
+	assert.True(t, status.Installed)
+	assert.False(t, status.HasUpdate)
+	assert.Equal(t, "11069520", status.LocalBuild)
+	assert.Equal(t, status.RemoteDepots, status.LocalDepots)
+
+	assert.Nil(t, os.WriteFile(acf, []byte(manifest("1")), 0644))
+	status, err = scmd.ServerStatus(context.Background(), 376030)
+	assert.Nil(t, err)
+	assert.True(t, status.HasUpdate)
+}
@@ -0,0 +1,175 @@
+package steamcmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// builtin mod shipped with the server
+const builtinModId = 111111111
+
+type ModCheck struct {
+	ModId    int
+	Title    string
+	Problems []string
+	// Unchecked are the checks that could not be done, not problems
+	Unchecked []string
+	// NoFiles is whether no workshop file list is recorded, the mod was
+	// installed before the list was and its file sizes are unchecked
+	NoFiles bool
+}
+
+func (check *ModCheck) Ok() bool {
+	return len(check.Problems) == 0
+}
+
+func (check *ModCheck) problemf(f string, args ...any) {
+	check.Problems = append(check.Problems, fmt.Sprintf(f, args...))
+}
+
+// InstalledMods returns the modids found in the mods directory
+func (steamcmd *SteamCmd) InstalledMods() (modIds []int, err error) {
+	entries, err := os.ReadDir(steamcmd.modsRoot())
+	if err != nil {
+		return nil, errors.Wrapf(err, "os.ReadDir(%v)", steamcmd.modsRoot())
+	}
+
+	found := map[int]bool{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		modId, err := strconv.Atoi(name)
+		if err != nil || modId == builtinModId {
+			continue
+		}
+		if !found[modId] {
+			found[modId] = true
+			modIds = append(modIds, modId)
+		}
+	}
+	sort.Ints(modIds)
+
+	return modIds, nil
+}
+
+func (steamcmd *SteamCmd) VerifyMods(ctx context.Context, appId int, modIds []int) (checks []*ModCheck, err error) {
+	for _, modId := range modIds {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		check, err := steamcmd.verifyMod(appId, modId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "steamcmd.verifyMod(%v)", modId)
+		}
+
+		if check.Ok() && len(check.Unchecked) > 0 {
+			steamcmd.SendUserf(": ARK MOD[%v](%v) is ok, not checked: %v", modId, check.Title, strings.Join(check.Unchecked, ", "))
+		} else if check.Ok() {
+			steamcmd.SendUserf(": ARK MOD[%v](%v) is ok", modId, check.Title)
+		} else {
+			steamcmd.SendUserf("! ARK MOD[%v](%v) is broken: %v", modId, check.Title, strings.Join(check.Problems, ", "))
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+func (steamcmd *SteamCmd) verifyMod(appId, modId int) (check *ModCheck, err error) {
+	check = &ModCheck{ModId: modId}
+
+	modPath := filepath.Join(steamcmd.modsRoot(), fmt.Sprintf("%v", modId))
+	modFile := filepath.Join(steamcmd.modsRoot(), fmt.Sprintf("%v.mod", modId))
+	yamlFile := filepath.Join(steamcmd.modsRoot(), fmt.Sprintf("%v.yaml", modId))
+
+	// .mod
+	if _, err := os.Stat(modFile); os.IsNotExist(err) {
+		check.problemf(".mod is missing")
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "os.Stat(%v)", modFile)
+	}
+
+	// mod dir
+	if info, err := os.Stat(modPath); os.IsNotExist(err) {
+		check.problemf("mod directory is missing")
+		return check, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "os.Stat(%v)", modPath)
+	} else if !info.IsDir() {
+		check.problemf("mod directory is not a directory")
+		return check, nil
+	}
+
+	// .yaml vs appworkshop acf
+	modInfo, err := steamcmd.readYaml(yamlFile)
+	if os.IsNotExist(err) {
+		check.problemf(".yaml is missing")
+		modInfo = &ModInfo{}
+	} else if err != nil {
+		check.problemf(".yaml is unreadable: %v", err)
+		modInfo = &ModInfo{}
+	} else {
+		check.Title = modInfo.Title
+
+		acfUpdated, err := steamcmd.readUpdatedFromAcf(appId, modId)
+		if err != nil {
+			check.problemf("appworkshop timeupdated is unknown: %v", err)
+		} else if acfUpdated != modInfo.Updated {
+			check.problemf(".yaml updated %v does not match appworkshop timeupdated %v", modInfo.Updated, acfUpdated)
+		}
+	}
+
+	// leftover compressed files
+	var zfiles int
+	if err := filepath.Walk(modPath, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && (filepath.Ext(path) == ".z" || strings.HasSuffix(path, ".z.uncompressed_size")) {
+			zfiles++
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "filepath.Walk(%v)", modPath)
+	}
+	if zfiles > 0 {
+		check.problemf("%v compressed files are left", zfiles)
+	}
+
+	// file sizes against those of the workshop download, recorded at install
+	// time. Mods installed before have no list until they are reinstalled.
+	if len(modInfo.Files) == 0 {
+		check.NoFiles = true
+		check.Unchecked = append(check.Unchecked, "file sizes (no workshop file list recorded at install)")
+	} else {
+		files, err := listModFiles(modPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "listModFiles")
+		}
+
+		var missing, mismatch int
+		for name, size := range modInfo.Files {
+			if actual, has := files[name]; !has {
+				missing++
+			} else if actual != size {
+				mismatch++
+			}
+		}
+		if missing > 0 {
+			check.problemf("%v files are missing", missing)
+		}
+		if mismatch > 0 {
+			check.problemf("%v files differ in size from the workshop download", mismatch)
+		}
+	}
+
+	return check, nil
+}
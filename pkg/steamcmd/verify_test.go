@@ -0,0 +1,95 @@
+package steamcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// installMod writes an installed mod, its .yaml recording files and the
+// appworkshop acf entry updated at 100
+func installMod(t *testing.T, scmd *SteamCmd, modId int, files map[string]string, recorded map[string]int64) {
+	modPath := filepath.Join(scmd.modsRoot(), fmt.Sprintf("%v", modId))
+	for name, content := range files {
+		path := filepath.Join(modPath, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	assert.Nil(t, os.MkdirAll(modPath, 0755))
+	assert.Nil(t, os.WriteFile(modPath+".mod", []byte("mod"), 0644))
+	assert.Nil(t, scmd.writeYaml(modPath+".yaml", &ModInfo{Title: fmt.Sprintf("mod%v", modId), Updated: 100, Files: recorded}))
+
+	acf := filepath.Join(scmd.installDir, "steamapps", "workshop", "appworkshop_346110.acf")
+	assert.Nil(t, os.MkdirAll(filepath.Dir(acf), 0755))
+	f, err := os.OpenFile(acf, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	assert.Nil(t, err)
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "\"AppWorkshop\"\n{\n\t\"WorkshopItemDetails\"\n\t{\n\t\t\"%v\"\n\t\t{\n\t\t\t\"timeupdated\"\t\t\"100\"\n\t\t}\n\t}\n}\n", modId)
+	assert.Nil(t, err)
+}
+
+func TestVerifyMods(t *testing.T) {
+	scmd := NewSteamCmd("steamcmd", t.TempDir())
+
+	// ok
+	installMod(t, scmd, 1, map[string]string{"mod.info": "info", "Maps/a.umap": "abc"},
+		map[string]int64{"mod.info": 4, "Maps/a.umap": 3})
+	// a truncated, a missing file and a leftover compressed file
+	installMod(t, scmd, 2, map[string]string{"mod.info": "info", "Maps/a.umap": "ab", "b.uasset.z": "z"},
+		map[string]int64{"mod.info": 4, "Maps/a.umap": 3, "b.uasset": 10})
+	// installed before the file list was recorded
+	installMod(t, scmd, 3, map[string]string{"mod.info": "info"}, nil)
+	// a .mod without a directory
+	assert.Nil(t, os.WriteFile(filepath.Join(scmd.modsRoot(), "4.mod"), []byte("mod"), 0644))
+	// the builtin mod and other files are not mods
+	assert.Nil(t, os.MkdirAll(filepath.Join(scmd.modsRoot(), "111111111"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(scmd.modsRoot(), "readme.txt"), nil, 0644))
+
+	modIds, err := scmd.InstalledMods()
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, modIds)
+
+	checks, err := scmd.VerifyMods(context.Background(), 346110, modIds)
+	assert.Nil(t, err)
+	if !assert.Len(t, checks, 4) {
+		return
+	}
+
+	assert.Equal(t, &ModCheck{ModId: 1, Title: "mod1"}, checks[0])
+
+	assert.Equal(t, []string{
+		"1 compressed files are left",
+		"1 files are missing",
+		"1 files differ in size from the workshop download",
+	}, checks[1].Problems)
+
+	assert.True(t, checks[2].Ok())
+	assert.True(t, checks[2].NoFiles)
+	assert.Len(t, checks[2].Unchecked, 1)
+
+	assert.Equal(t, []string{"mod directory is missing"}, checks[3].Problems)
+}
+
+func TestVerifyModYaml(t *testing.T) {
+	scmd := NewSteamCmd("steamcmd", t.TempDir())
+	installMod(t, scmd, 1, map[string]string{"mod.info": "info"}, map[string]int64{"mod.info": 4})
+	modPath := filepath.Join(scmd.modsRoot(), "1")
+
+	// updated since the acf was written
+	assert.Nil(t, scmd.writeYaml(modPath+".yaml", &ModInfo{Title: "mod1", Updated: 200, Files: map[string]int64{"mod.info": 4}}))
+	check, err := scmd.verifyMod(346110, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".yaml updated 200 does not match appworkshop timeupdated 100"}, check.Problems)
+
+	// without .yaml and .mod
+	assert.Nil(t, os.Remove(modPath+".yaml"))
+	assert.Nil(t, os.Remove(modPath+".mod"))
+	check, err = scmd.verifyMod(346110, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".mod is missing", ".yaml is missing"}, check.Problems)
+	assert.True(t, check.NoFiles)
+}